@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+type runAnnouncement struct {
+	slackChannelID string
+	tmpl           *template.Template
+}
+
+// announcementTemplateData is passed to the announcement template for each
+// schedule whose on-call users changed.
+type announcementTemplateData struct {
+	Schedule string
+	Incoming slackUserIDs
+	Outgoing slackUserIDs
+}
+
+func (sp syncerParams) createAnnouncement(slSyncName string, cfgSlSync ConfigSlackSync, slChannels channelList) (*runAnnouncement, error) {
+	cfgAnnouncement := cfgSlSync.Announcement
+
+	tmpl, err := template.New("announcement").Funcs(templateFuncs).Parse(cfgAnnouncement.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %s", cfgAnnouncement.Template, err)
+	}
+
+	cfgChannel := cfgAnnouncement.Channel
+	if cfgChannel.ID == "" && cfgChannel.Name == "" {
+		cfgChannel = cfgSlSync.Channel
+	}
+	slChannel := slChannels.find(cfgChannel.ID, cfgChannel.Name)
+	if slChannel == nil {
+		return nil, fmt.Errorf("failed to find configured Slack channel %s", cfgChannel)
+	}
+	fmt.Printf("Slack sync %s: found Slack announcement channel %q (ID %s)\n", slSyncName, slChannel.Name, slChannel.ID)
+
+	return &runAnnouncement{
+		slackChannelID: slChannel.ID,
+		tmpl:           tmpl,
+	}, nil
+}
+
+// runAnnouncement posts a message for every schedule whose on-call users
+// changed since the previous run. Nothing is posted while the previous
+// on-calls are unknown. Schedules are tracked until the announcements of all
+// schedules were posted so that a failed post is retried for the affected
+// schedules only.
+func (s *syncer) runAnnouncement(ctx context.Context, announcement runAnnouncement, slackSync runSlackSync, prevOnCalls, onCalls []scheduleOnCall) error {
+	if prevOnCalls == nil {
+		fmt.Println("Previous on-call users unknown, not posting announcements")
+		return nil
+	}
+
+	var errs []error
+	for _, onCall := range onCalls {
+		prevUsers, ok := findOnCallUsers(prevOnCalls, onCall.schedule.id)
+		if !ok || sameOnCallUsers(prevUsers, onCall.users) {
+			continue
+		}
+		if containsString(s.handoffAnnouncements[slackSync.name], onCall.schedule.id) {
+			fmt.Printf("Announcement for schedule %q already posted\n", onCall.schedule.name)
+			continue
+		}
+
+		incoming, err := slackSync.slackUserIDsFor(subtractOnCallUsers(onCall.users, prevUsers))
+		if err != nil {
+			return err
+		}
+		outgoing, err := slackSync.slackUserIDsFor(subtractOnCallUsers(prevUsers, onCall.users))
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		data := announcementTemplateData{
+			Schedule: onCall.schedule.name,
+			Incoming: incoming,
+			Outgoing: outgoing,
+		}
+		if err := announcement.tmpl.Execute(&buf, data); err != nil {
+			return fmt.Errorf("failed to render template: %s", err)
+		}
+
+		if err := slackSync.slack.client.postMessage(ctx, announcement.slackChannelID, buf.String(), slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post announcement for schedule %q: %s", onCall.schedule.name, err))
+			continue
+		}
+		if !slackSync.dryRun {
+			s.handoffAnnouncements[slackSync.name] = append(s.handoffAnnouncements[slackSync.name], onCall.schedule.id)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	delete(s.handoffAnnouncements, slackSync.name)
+
+	return nil
+}
+
+// slackUserIDsFor returns the Slack user IDs of the given on-call users,
+// escaped if the sync pretends users.
+func (rss runSlackSync) slackUserIDsFor(users []oncallUser) (slackUserIDs, error) {
+	slUserIDs := slackUserIDs{}
+	for _, user := range users {
+		slUser := rss.slack.users.findByOncallUser(user)
+		if slUser == nil {
+			return nil, fmt.Errorf("failed to find Slack user for on-call user %s", user)
+		}
+		slUserID := slUser.id
+		if rss.pretendUsers {
+			slUserID = fmt.Sprintf(`\%s`, slUserID)
+		}
+		slUserIDs = append(slUserIDs, slUserID)
+	}
+	return slUserIDs, nil
+}
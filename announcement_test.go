@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+func TestRunAnnouncement(t *testing.T) {
+	var messages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", r.PostForm.Get("channel"), r.PostForm.Get("text")))
+		fmt.Fprint(w, `{"ok": true, "channel": "C1", "ts": "1.0"}`)
+	}))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	announcement := runAnnouncement{
+		slackChannelID: "C1",
+		tmpl:           template.Must(template.New("announcement").Funcs(templateFuncs).Parse("{{mentions .Incoming}} takes over {{.Schedule}} from {{mentions .Outgoing}}")),
+	}
+	slackSync := runSlackSync{name: "team", slack: conn}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	onCallsJane := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}}}
+	onCallsJohn := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{john}}}
+
+	for _, run := range []struct {
+		prev []scheduleOnCall
+		cur  []scheduleOnCall
+	}{
+		{prev: nil, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJohn},
+	} {
+		if err := s.runAnnouncement(context.Background(), announcement, slackSync, run.prev, run.cur); err != nil {
+			t.Fatalf("failed to run announcement: %s", err)
+		}
+	}
+
+	want := []string{"C1: <@U2> takes over Primary from <@U1>"}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunAnnouncementRetriesFailedSchedules(t *testing.T) {
+	failing := true
+	var messages []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		text := r.PostForm.Get("text")
+		if failing && strings.Contains(text, "Secondary") {
+			fmt.Fprint(w, `{"ok": false, "error": "internal_error"}`)
+			return
+		}
+		messages = append(messages, text)
+		fmt.Fprint(w, `{"ok": true, "channel": "C1", "ts": "1.0"}`)
+	}))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users:  slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	s := newSyncer(syncerParams{})
+	announcement := runAnnouncement{
+		slackChannelID: "C1",
+		tmpl:           template.Must(template.New("announcement").Funcs(templateFuncs).Parse("{{mentions .Incoming}} takes over {{.Schedule}}")),
+	}
+	slackSync := runSlackSync{name: "team", slack: conn}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	prevOnCalls := []scheduleOnCall{
+		{schedule: oncallSchedule{id: "S1", name: "Primary"}},
+		{schedule: oncallSchedule{id: "S2", name: "Secondary"}},
+	}
+	onCalls := []scheduleOnCall{
+		{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}},
+		{schedule: oncallSchedule{id: "S2", name: "Secondary"}, users: []oncallUser{jane}},
+	}
+
+	if err := s.runAnnouncement(context.Background(), announcement, slackSync, prevOnCalls, onCalls); err == nil {
+		t.Fatal("got no error for failing post, want one")
+	}
+	failing = false
+	if err := s.runAnnouncement(context.Background(), announcement, slackSync, prevOnCalls, onCalls); err != nil {
+		t.Fatalf("failed to retry announcement: %s", err)
+	}
+	if len(s.handoffAnnouncements) != 0 {
+		t.Errorf("got handoff announcements %v after complete delivery, want none", s.handoffAnnouncements)
+	}
+
+	want := []string{"<@U1> takes over Primary", "<@U1> takes over Secondary"}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%s", diff)
+	}
+}
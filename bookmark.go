@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+type runBookmark struct {
+	titleTmpl *template.Template
+	linkTmpl  *template.Template
+	emoji     string
+	loc       *time.Location
+}
+
+func createBookmark(cfgBookmark ConfigBookmark) (*runBookmark, error) {
+	titleTmpl, err := template.New("bookmark-title").Parse(cfgBookmark.Title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse title template %q: %s", cfgBookmark.Title, err)
+	}
+	linkTmpl, err := template.New("bookmark-link").Parse(cfgBookmark.Link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse link template %q: %s", cfgBookmark.Link, err)
+	}
+
+	loc := time.UTC
+	if cfgBookmark.TimeZone != "" {
+		loc, err = time.LoadLocation(cfgBookmark.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load time zone %q: %s", cfgBookmark.TimeZone, err)
+		}
+	}
+
+	return &runBookmark{
+		titleTmpl: titleTmpl,
+		linkTmpl:  linkTmpl,
+		emoji:     cfgBookmark.Emoji,
+		loc:       loc,
+	}, nil
+}
+
+// runBookmark creates or updates the sync's bookmark in the Slack channel.
+// The bookmark is identified by the ID remembered from previous runs, or by
+// its link if the ID is unknown.
+func (s *syncer) runBookmark(ctx context.Context, bookmark runBookmark, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	onCallsByScheduleName := map[string]onCallSummary{}
+	for _, onCall := range onCalls {
+		var summary onCallSummary
+		summary.add(onCall.users, bookmark.loc)
+		onCallsByScheduleName[templateVarName(onCall.schedule.name)] = summary
+	}
+
+	var title, link bytes.Buffer
+	if err := bookmark.titleTmpl.Execute(&title, onCallsByScheduleName); err != nil {
+		return fmt.Errorf("failed to render title template: %s", err)
+	}
+	if err := bookmark.linkTmpl.Execute(&link, onCallsByScheduleName); err != nil {
+		return fmt.Errorf("failed to render link template: %s", err)
+	}
+
+	bookmarkID, err := slackSync.slack.client.updateBookmark(ctx, slackSync.slackChannelID, s.bookmarkIDs[slackSync.name], title.String(), link.String(), bookmark.emoji, slackSync.dryRun)
+	if err != nil {
+		return err
+	}
+	if bookmarkID != "" {
+		s.bookmarkIDs[slackSync.name] = bookmarkID
+	}
+
+	return nil
+}
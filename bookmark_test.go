@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/slack-go/slack"
+)
+
+// fakeSlackBookmarks is a minimal stand-in for the Slack bookmarks API.
+type fakeSlackBookmarks struct {
+	bookmarks []slack.Bookmark
+	nextID    int
+}
+
+func (fsb *fakeSlackBookmarks) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		if got := r.PostForm.Get("channel_id"); got != "C1" {
+			t.Errorf("got channel ID %q, want C1", got)
+		}
+
+		switch r.URL.Path {
+		case "/bookmarks.list":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "bookmarks": fsb.bookmarks})
+		case "/bookmarks.add":
+			fsb.nextID++
+			bm := slack.Bookmark{
+				ID:    fmt.Sprintf("Bk%d", fsb.nextID),
+				Title: r.PostForm.Get("title"),
+				Link:  r.PostForm.Get("link"),
+				Emoji: r.PostForm.Get("emoji"),
+			}
+			fsb.bookmarks = append(fsb.bookmarks, bm)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "bookmark": bm})
+		case "/bookmarks.edit":
+			for i, bm := range fsb.bookmarks {
+				if bm.ID == r.PostForm.Get("bookmark_id") {
+					fsb.bookmarks[i].Title = r.PostForm.Get("title")
+					fsb.bookmarks[i].Link = r.PostForm.Get("link")
+					fsb.bookmarks[i].Emoji = r.PostForm.Get("emoji")
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "bookmark": fsb.bookmarks[i]})
+					return
+				}
+			}
+			fmt.Fprint(w, `{"ok": false, "error": "not_found"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRunBookmark(t *testing.T) {
+	fsb := &fakeSlackBookmarks{
+		bookmarks: []slack.Bookmark{
+			{ID: "Bk0", Title: "Runbook", Link: "https://example.com/runbook"},
+		},
+	}
+	srv := httptest.NewServer(fsb.handler(t))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+	}
+	s := newSyncer(syncerParams{})
+	bookmark, err := createBookmark(ConfigBookmark{
+		Title:    `On-call: {{.Primary}} (until {{.Primary.Until.Format "Mon 15:04"}})`,
+		Link:     "https://example.pagerduty.com/schedules/S1",
+		Emoji:    ":pager:",
+		TimeZone: "Europe/Berlin",
+	})
+	if err != nil {
+		t.Fatalf("failed to create bookmark: %s", err)
+	}
+	slackSync := runSlackSync{name: "team", slack: conn, slackChannelID: "C1"}
+
+	onCalls := func(name string, until time.Time) []scheduleOnCall {
+		return []scheduleOnCall{
+			{
+				schedule: oncallSchedule{id: "S1", name: "Primary"},
+				users:    []oncallUser{{id: name, name: name, until: until}},
+			},
+		}
+	}
+
+	for _, onCall := range [][]scheduleOnCall{
+		onCalls("Jane", time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC)),
+		onCalls("Jane", time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC)),
+		onCalls("John", time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)),
+	} {
+		if err := s.runBookmark(context.Background(), *bookmark, slackSync, onCall); err != nil {
+			t.Fatalf("failed to run bookmark: %s", err)
+		}
+	}
+
+	wantBookmarks := []slack.Bookmark{
+		{ID: "Bk0", Title: "Runbook", Link: "https://example.com/runbook"},
+		{ID: "Bk1", Title: "On-call: John (until Mon 09:00)", Link: "https://example.pagerduty.com/schedules/S1", Emoji: ":pager:"},
+	}
+	if diff := cmp.Diff(wantBookmarks, fsb.bookmarks, cmpopts.IgnoreFields(slack.Bookmark{}, "Created", "Updated")); diff != "" {
+		t.Errorf("bookmarks mismatch (-want +got):\n%s", diff)
+	}
+	if got := s.bookmarkIDs["team"]; got != "Bk1" {
+		t.Errorf("got remembered bookmark ID %q, want Bk1", got)
+	}
+}
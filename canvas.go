@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultCanvasLookahead = 7 * 24 * time.Hour
+
+const defaultCanvasTemplate = `# On call
+{{range .Schedules}}
+## {{.Name}}{{if .EscalationPolicy}} (escalation policy){{end}}
+
+**On call:** {{with .OnCall}}{{canvasMentions .}}{{else}}nobody{{end}}{{if not .Until.IsZero}} until {{.Until.Format "Mon Jan 2 15:04 MST"}}{{end}}
+{{with .UserGroups}}
+**Escalate via:** {{join . ", "}}
+{{end}}{{with .Upcoming}}
+**Upcoming shifts:**
+{{range .}}
+- {{.Start.Format "Mon Jan 2 15:04"}} to {{.End.Format "Mon Jan 2 15:04 MST"}}: {{if .SlackID}}{{canvasMention .SlackID}}{{else}}{{.Name}}{{end}}{{end}}
+{{end}}{{end}}`
+
+var canvasTemplateFuncs = template.FuncMap{
+	"canvasMention":  canvasMention,
+	"canvasMentions": canvasMentions,
+	"join":           strings.Join,
+}
+
+type runCanvas struct {
+	tmpl      *template.Template
+	lookahead time.Duration
+	loc       *time.Location
+	takeOver  bool
+}
+
+// canvasDocument identifies the canvas of a sync and the content it was last
+// rendered with.
+type canvasDocument struct {
+	ID string `json:"id"`
+	// Digest is the SHA-256 digest of the rendered Markdown.
+	Digest string `json:"digest"`
+}
+
+// canvasTemplateData is the data the canvas template is executed with.
+type canvasTemplateData struct {
+	Schedules []canvasSchedule
+}
+
+type canvasSchedule struct {
+	Name string
+	// EscalationPolicy is true if the schedule is a PagerDuty escalation
+	// policy.
+	EscalationPolicy bool
+	// OnCall holds the Slack user IDs of the users currently on call.
+	OnCall slackUserIDs
+	// Until is the earliest end of the current shifts, or zero if unknown.
+	Until time.Time
+	// UserGroups holds the handles of the schedule's user groups.
+	UserGroups []string
+	// Upcoming holds the shifts starting within the lookahead.
+	Upcoming []canvasShift
+}
+
+type canvasShift struct {
+	Name string
+	// SlackID is the Slack user ID of the user, or empty if the user was not
+	// found in Slack.
+	SlackID string
+	Start   time.Time
+	End     time.Time
+}
+
+func createCanvas(cfgCanvas ConfigCanvas) (*runCanvas, error) {
+	tmplString := cfgCanvas.Template
+	if tmplString == "" {
+		tmplString = defaultCanvasTemplate
+	}
+	tmpl, err := template.New("canvas").Funcs(canvasTemplateFuncs).Parse(tmplString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %s", tmplString, err)
+	}
+
+	lookahead := cfgCanvas.Lookahead
+	if lookahead == 0 {
+		lookahead = defaultCanvasLookahead
+	}
+
+	loc := time.UTC
+	if cfgCanvas.TimeZone != "" {
+		loc, err = time.LoadLocation(cfgCanvas.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load time zone %q: %s", cfgCanvas.TimeZone, err)
+		}
+	}
+
+	return &runCanvas{
+		tmpl:      tmpl,
+		lookahead: lookahead,
+		loc:       loc,
+		takeOver:  cfgCanvas.TakeOver,
+	}, nil
+}
+
+// runCanvas keeps the canvas of the Slack channel up-to-date. The canvas is
+// only edited if the rendered content changed since the last update. An
+// existing channel canvas that pdsync did not create is only taken over if
+// configured; if the canvas was deleted, a new one is created.
+func (s *syncer) runCanvas(ctx context.Context, canvas runCanvas, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	data, err := s.canvasTemplateData(ctx, canvas, slackSync, onCalls)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := canvas.tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to render template: %s", err)
+	}
+	markdown := buf.String()
+	sum := sha256.Sum256(buf.Bytes())
+	digest := hex.EncodeToString(sum[:])
+
+	doc := s.canvases[slackSync.name]
+	if doc.ID == "" && canvas.takeOver {
+		doc.ID, err = slackSync.slack.client.getChannelCanvasID(ctx, slackSync.slackChannelID)
+		if err != nil {
+			return fmt.Errorf("failed to get canvas of channel %s: %s", slackSync.slackChannelID, err)
+		}
+	}
+
+	if doc.ID != "" {
+		if doc.Digest == digest {
+			fmt.Println("Canvas already set correctly")
+			return nil
+		}
+
+		fmt.Printf("Updating canvas %s\n", doc.ID)
+		err := slackSync.slack.client.editCanvas(ctx, doc.ID, markdown, slackSync.dryRun)
+		if err == nil {
+			if !slackSync.dryRun {
+				s.canvases[slackSync.name] = canvasDocument{ID: doc.ID, Digest: digest}
+			}
+			return nil
+		}
+		if !isSlackError(err, "canvas_not_found") {
+			return fmt.Errorf("failed to update canvas: %s", err)
+		}
+		fmt.Printf("Canvas %s not found, creating a new one\n", doc.ID)
+	}
+
+	id, err := slackSync.slack.client.createChannelCanvas(ctx, slackSync.slackChannelID, markdown, slackSync.dryRun)
+	if isSlackError(err, "channel_canvas_already_exists") {
+		return fmt.Errorf("channel %s already has a canvas not known to pdsync; enable takeOver to overwrite it or configure a state file to remember canvases across restarts", slackSync.slackChannelID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create canvas: %s", err)
+	}
+	if !slackSync.dryRun {
+		s.canvases[slackSync.name] = canvasDocument{ID: id, Digest: digest}
+	}
+
+	return nil
+}
+
+func (s *syncer) canvasTemplateData(ctx context.Context, canvas runCanvas, slackSync runSlackSync, onCalls []scheduleOnCall) (canvasTemplateData, error) {
+	var data canvasTemplateData
+	for _, onCall := range onCalls {
+		var summary onCallSummary
+		summary.add(onCall.users, canvas.loc)
+		cSchedule := canvasSchedule{
+			Name:             onCall.schedule.name,
+			EscalationPolicy: onCall.schedule.isEscalationPolicy(),
+			OnCall:           slackUserIDs{},
+			Until:            summary.Until,
+		}
+		// Canvas mentions do not notify users, so there is no need to pretend
+		// users.
+		for _, user := range onCall.users {
+			slUser := slackSync.slack.users.findByOncallUser(user)
+			if slUser == nil {
+				return canvasTemplateData{}, fmt.Errorf("failed to find Slack user for on-call user %s", user)
+			}
+			cSchedule.OnCall = append(cSchedule.OnCall, slUser.id)
+		}
+		for _, userGroup := range onCall.schedule.userGroups {
+			cSchedule.UserGroups = append(cSchedule.UserGroups, "@"+userGroup.Handle)
+		}
+
+		if provider, ok := onCall.schedule.provider.(upcomingShiftProvider); ok {
+			fmt.Printf("Getting upcoming shifts for schedule %s\n", onCall.schedule)
+			shifts, err := provider.getUpcomingShifts(ctx, onCall.schedule, time.Now().Add(canvas.lookahead))
+			if err != nil {
+				return canvasTemplateData{}, fmt.Errorf("failed to get upcoming shifts for schedule %q: %s", onCall.schedule.name, err)
+			}
+			for _, shift := range shifts {
+				cShift := canvasShift{
+					Name:  shift.user.name,
+					Start: shift.start.In(canvas.loc),
+					End:   shift.end.In(canvas.loc),
+				}
+				if slUser := slackSync.slack.users.findByOncallUser(shift.user); slUser != nil {
+					cShift.SlackID = slUser.id
+				}
+				cSchedule.Upcoming = append(cSchedule.Upcoming, cShift)
+			}
+		}
+
+		data.Schedules = append(data.Schedules, cSchedule)
+	}
+
+	return data, nil
+}
+
+// canvasMention renders the given Slack user ID as a canvas user mention.
+func canvasMention(id string) string {
+	return fmt.Sprintf("![](@%s)", id)
+}
+
+// canvasMentions renders the given Slack user IDs as a comma-separated list
+// of canvas user mentions.
+func canvasMentions(ids slackUserIDs) string {
+	userMentions := make([]string, 0, len(ids))
+	for _, id := range ids {
+		userMentions = append(userMentions, canvasMention(id))
+	}
+	return strings.Join(userMentions, ", ")
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+// fakeSlackCanvases is a minimal stand-in for the Slack canvases API.
+type fakeSlackCanvases struct {
+	// canvases holds the Markdown content of the canvases by ID.
+	canvases      map[string]string
+	channelCanvas string
+	requests      []string
+	nextID        int
+}
+
+func (fsc *fakeSlackCanvases) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fsc.requests = append(fsc.requests, r.URL.Path)
+
+		if r.URL.Path == "/conversations.info" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok": true,
+				"channel": map[string]interface{}{
+					"id":         "C1",
+					"properties": map[string]interface{}{"canvas": map[string]string{"file_id": fsc.channelCanvas}},
+				},
+			})
+			return
+		}
+
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("got authorization header %q, want bearer token", got)
+		}
+		var params struct {
+			ChannelID       string                `json:"channel_id"`
+			CanvasID        string                `json:"canvas_id"`
+			DocumentContent canvasDocumentContent `json:"document_content"`
+			Changes         []struct {
+				Operation       string                `json:"operation"`
+				DocumentContent canvasDocumentContent `json:"document_content"`
+			} `json:"changes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("failed to decode request: %s", err)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/conversations.canvases.create":
+			if params.ChannelID != "C1" {
+				t.Errorf("got channel %q, want C1", params.ChannelID)
+			}
+			if _, ok := fsc.canvases[fsc.channelCanvas]; ok {
+				fmt.Fprint(w, `{"ok": false, "error": "channel_canvas_already_exists"}`)
+				return
+			}
+			fsc.nextID++
+			id := fmt.Sprintf("F%d", fsc.nextID)
+			fsc.canvases[id] = params.DocumentContent.Markdown
+			fsc.channelCanvas = id
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "canvas_id": id})
+		case "/canvases.edit":
+			if _, ok := fsc.canvases[params.CanvasID]; !ok {
+				fmt.Fprint(w, `{"ok": false, "error": "canvas_not_found"}`)
+				return
+			}
+			if len(params.Changes) != 1 || params.Changes[0].Operation != "replace" {
+				t.Errorf("got changes %+v, want a single replace operation", params.Changes)
+			}
+			fsc.canvases[params.CanvasID] = params.Changes[0].DocumentContent.Markdown
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRunCanvas(t *testing.T) {
+	fsc := &fakeSlackCanvases{
+		canvases:      map[string]string{"F0": "Welcome"},
+		channelCanvas: "F0",
+	}
+	srv := httptest.NewServer(fsc.handler(t))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{
+			slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/")),
+			httpClient:  srv.Client(),
+			apiURL:      srv.URL + "/",
+			token:       "token",
+		},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	canvas, err := createCanvas(ConfigCanvas{
+		Template: "{{range .Schedules}}{{.Name}}: {{canvasMentions .OnCall}}{{end}}",
+		TakeOver: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create canvas: %s", err)
+	}
+	slackSync := runSlackSync{name: "team", slack: conn, slackChannelID: "C1"}
+	onCalls := func(email string) []scheduleOnCall {
+		return []scheduleOnCall{
+			{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{{email: email}}},
+		}
+	}
+
+	steps := []struct {
+		name         string
+		onCalls      []scheduleOnCall
+		deleteID     string
+		wantRequests []string
+		wantID       string
+		wantMarkdown string
+	}{
+		{
+			name:         "take over channel canvas",
+			onCalls:      onCalls("jane@example.com"),
+			wantRequests: []string{"/conversations.info", "/canvases.edit"},
+			wantID:       "F0",
+			wantMarkdown: "Primary: ![](@U1)",
+		},
+		{
+			name:         "keep unchanged canvas",
+			onCalls:      onCalls("jane@example.com"),
+			wantID:       "F0",
+			wantMarkdown: "Primary: ![](@U1)",
+		},
+		{
+			name:         "update changed canvas",
+			onCalls:      onCalls("john@example.com"),
+			wantRequests: []string{"/canvases.edit"},
+			wantID:       "F0",
+			wantMarkdown: "Primary: ![](@U2)",
+		},
+		{
+			name:         "recreate deleted canvas",
+			onCalls:      onCalls("jane@example.com"),
+			deleteID:     "F0",
+			wantRequests: []string{"/canvases.edit", "/conversations.canvases.create"},
+			wantID:       "F1",
+			wantMarkdown: "Primary: ![](@U1)",
+		},
+	}
+
+	for _, step := range steps {
+		fsc.requests = nil
+		if step.deleteID != "" {
+			delete(fsc.canvases, step.deleteID)
+		}
+		if err := s.runCanvas(context.Background(), *canvas, slackSync, step.onCalls); err != nil {
+			t.Fatalf("step %q: failed to run canvas: %s", step.name, err)
+		}
+		if diff := cmp.Diff(step.wantRequests, fsc.requests); diff != "" {
+			t.Errorf("step %q: requests mismatch (-want +got):\n%s", step.name, diff)
+		}
+		if got := s.canvases["team"].ID; got != step.wantID {
+			t.Errorf("step %q: got remembered canvas ID %q, want %q", step.name, got, step.wantID)
+		}
+		if got := fsc.canvases[step.wantID]; got != step.wantMarkdown {
+			t.Errorf("step %q: got canvas content %q, want %q", step.name, got, step.wantMarkdown)
+		}
+	}
+}
+
+func TestRunCanvasWithoutTakeOver(t *testing.T) {
+	tests := []struct {
+		name          string
+		channelCanvas string
+		wantErrStr    string
+		wantRequests  []string
+		wantID        string
+	}{
+		{
+			name:         "create channel canvas",
+			wantRequests: []string{"/conversations.canvases.create"},
+			wantID:       "F1",
+		},
+		{
+			name:          "do not take over channel canvas",
+			channelCanvas: "F0",
+			wantErrStr:    "enable takeOver",
+			wantRequests:  []string{"/conversations.canvases.create"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsc := &fakeSlackCanvases{
+				canvases:      map[string]string{"F0": "Welcome"},
+				channelCanvas: tt.channelCanvas,
+			}
+			srv := httptest.NewServer(fsc.handler(t))
+			defer srv.Close()
+
+			conn := &slackConnection{
+				client: &slackMetaClient{
+					slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/")),
+					httpClient:  srv.Client(),
+					apiURL:      srv.URL + "/",
+					token:       "token",
+				},
+				users: slackUsers{{id: "U1", email: "jane@example.com"}},
+			}
+			s := newSyncer(syncerParams{})
+			canvas, err := createCanvas(ConfigCanvas{Template: "{{range .Schedules}}{{.Name}}: {{canvasMentions .OnCall}}{{end}}"})
+			if err != nil {
+				t.Fatalf("failed to create canvas: %s", err)
+			}
+			slackSync := runSlackSync{name: "team", slack: conn, slackChannelID: "C1"}
+			onCalls := []scheduleOnCall{
+				{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{{email: "jane@example.com"}}},
+			}
+
+			err = s.runCanvas(context.Background(), *canvas, slackSync, onCalls)
+			var gotErrStr string
+			if err != nil {
+				gotErrStr = err.Error()
+			}
+			if tt.wantErrStr == "" && err != nil {
+				t.Fatalf("failed to run canvas: %s", err)
+			}
+			if !strings.Contains(gotErrStr, tt.wantErrStr) {
+				t.Errorf("got error string %q, want %q", gotErrStr, tt.wantErrStr)
+			}
+			if diff := cmp.Diff(tt.wantRequests, fsc.requests); diff != "" {
+				t.Errorf("requests mismatch (-want +got):\n%s", diff)
+			}
+			if got := s.canvases["team"].ID; got != tt.wantID {
+				t.Errorf("got remembered canvas ID %q, want %q", got, tt.wantID)
+			}
+			if got := fsc.canvases["F0"]; got != "Welcome" {
+				t.Errorf("got content %q of existing canvas, want it untouched", got)
+			}
+		})
+	}
+}
+
+func TestSyncSlackSetsStatusesDespiteFailingCanvas(t *testing.T) {
+	fsc := &fakeSlackCanvases{
+		canvases:      map[string]string{"F0": "Welcome"},
+		channelCanvas: "F0",
+	}
+	canvasSrv := httptest.NewServer(fsc.handler(t))
+	defer canvasSrv.Close()
+	statuses := map[string]slackStatus{}
+	statusSrv := newStatusTestServer(t, statuses, map[string]int{})
+	defer statusSrv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{
+			slackClient: slack.New("token", slack.OptionAPIURL(canvasSrv.URL+"/")),
+			httpClient:  canvasSrv.Client(),
+			apiURL:      canvasSrv.URL + "/",
+			token:       "token",
+		},
+		statusClient: &slackStatusClient{slackClient: slack.New("token", slack.OptionAPIURL(statusSrv.URL+"/"))},
+		users:        slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	s := newSyncer(syncerParams{})
+	canvas, err := createCanvas(ConfigCanvas{Template: "{{range .Schedules}}{{.Name}}{{end}}"})
+	if err != nil {
+		t.Fatalf("failed to create canvas: %s", err)
+	}
+	slackSync := runSlackSync{name: "team", slack: conn, slackChannelID: "C1", canvas: canvas}
+	schedule := oncallSchedule{id: "S1", name: "Primary", slackStatus: newSlackStatus(ConfigStatus{}, "Primary")}
+	onCalls := []scheduleOnCall{{schedule: schedule, users: []oncallUser{{email: "jane@example.com"}}}}
+
+	err = s.syncSlack(context.Background(), slackSync, onCalls)
+	if err == nil || !strings.Contains(err.Error(), "failed to update canvas") {
+		t.Errorf("got error %v, want canvas failure", err)
+	}
+	want := slackStatus{Text: "On call for Primary", Emoji: ":pager:"}
+	if got := statuses["U1"]; got != want {
+		t.Errorf("got status %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateCanvasDefaultTemplate(t *testing.T) {
+	canvas, err := createCanvas(ConfigCanvas{})
+	if err != nil {
+		t.Fatalf("failed to create canvas: %s", err)
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	data := canvasTemplateData{
+		Schedules: []canvasSchedule{
+			{
+				Name:       "Primary",
+				OnCall:     slackUserIDs{"U1"},
+				Until:      start,
+				UserGroups: []string{"@oncall"},
+				Upcoming: []canvasShift{
+					{Name: "John", SlackID: "U2", Start: start, End: start.Add(7 * 24 * time.Hour)},
+					{Name: "Max", Start: start.Add(7 * 24 * time.Hour), End: start.Add(14 * 24 * time.Hour)},
+				},
+			},
+			{
+				Name:             "Escalation",
+				EscalationPolicy: true,
+				OnCall:           slackUserIDs{},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := canvas.tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("failed to render template: %s", err)
+	}
+
+	want := `# On call
+
+## Primary
+
+**On call:** ![](@U1) until Mon Jan 8 09:00 UTC
+
+**Escalate via:** @oncall
+
+**Upcoming shifts:**
+
+- Mon Jan 8 09:00 to Mon Jan 15 09:00 UTC: ![](@U2)
+- Mon Jan 15 09:00 to Mon Jan 22 09:00 UTC: Max
+
+## Escalation (escalation policy)
+
+**On call:** nobody
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("rendered canvas mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// onCallCommand answers the /oncall slash command with the users currently on
+// call for the configured schedules.
+type onCallCommand struct {
+	slackSyncs []runSlackSync
+	slackUsers slackUsers
+}
+
+// answer returns the reply to the given query, which may name a sync or a
+// schedule. An empty query covers all schedules.
+func (cmd onCallCommand) answer(ctx context.Context, query string) string {
+	query = strings.TrimSpace(query)
+	schedules := cmd.findSchedules(query)
+	if len(schedules) == 0 {
+		var names []string
+		for _, slackSync := range cmd.slackSyncs {
+			names = appendUnique(names, slackSync.name)
+			for _, schedule := range slackSync.schedules {
+				names = appendUnique(names, schedule.name)
+			}
+		}
+		return fmt.Sprintf("No sync or schedule named %q. Try one of: %s", query, strings.Join(names, ", "))
+	}
+
+	return cmd.renderOnCalls(ctx, schedules)
+}
+
+// answerChannel returns the on-call state of the syncs whose topic, purpose,
+// bookmark, or roster is managed in the given channel.
+func (cmd onCallCommand) answerChannel(ctx context.Context, channelID string) string {
+	var schedules oncallSchedules
+	for _, slackSync := range cmd.slackSyncs {
+		if slackSync.slackChannelID != channelID {
+			continue
+		}
+		for _, schedule := range slackSync.schedules {
+			schedules.ensureSchedule(schedule)
+		}
+	}
+	if len(schedules) == 0 {
+		return "No slack sync is bound to this channel. Try the /oncall command instead."
+	}
+
+	return cmd.renderOnCalls(ctx, schedules)
+}
+
+// renderOnCalls returns one line per schedule listing its on-call users and
+// user group handles.
+func (cmd onCallCommand) renderOnCalls(ctx context.Context, schedules oncallSchedules) string {
+	lines := make([]string, 0, len(schedules))
+	for _, schedule := range schedules {
+		users, err := schedule.provider.getOnCallUsers(ctx, schedule)
+		if err != nil {
+			fmt.Printf("Failed to get on-call users for schedule %s: %s\n", schedule, err)
+			lines = append(lines, fmt.Sprintf("*%s*: failed to get on-call users", schedule.name))
+			continue
+		}
+
+		var names []string
+		for _, user := range users {
+			if slUser := cmd.slackUsers.findByOncallUser(user); slUser != nil {
+				names = append(names, fmt.Sprintf("<@%s>", slUser.id))
+			} else {
+				names = append(names, user.name)
+			}
+		}
+		if len(names) == 0 {
+			names = append(names, "nobody")
+		}
+
+		line := fmt.Sprintf("*%s*: %s", schedule.name, strings.Join(names, ", "))
+		if len(schedule.userGroups) > 0 {
+			var handles []string
+			for _, userGroup := range schedule.userGroups {
+				handles = append(handles, fmt.Sprintf("<!subteam^%s|@%s>", userGroup.ID, userGroup.Handle))
+			}
+			line += fmt.Sprintf(" (%s)", strings.Join(handles, ", "))
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// findSchedules returns the schedules of the sync with the given name, or
+// else the schedules with the given name. Names are matched
+// case-insensitively.
+func (cmd onCallCommand) findSchedules(query string) oncallSchedules {
+	var schedules oncallSchedules
+	for _, slackSync := range cmd.slackSyncs {
+		if query == "" || strings.EqualFold(slackSync.name, query) {
+			for _, schedule := range slackSync.schedules {
+				schedules.ensureSchedule(schedule)
+			}
+		}
+	}
+	if len(schedules) > 0 {
+		return schedules
+	}
+
+	for _, slackSync := range cmd.slackSyncs {
+		for _, schedule := range slackSync.schedules {
+			if strings.EqualFold(schedule.name, query) {
+				schedules.ensureSchedule(schedule)
+			}
+		}
+	}
+	return schedules
+}
+
+// respond answers the given slash command ephemerally through its response
+// URL.
+func (cmd onCallCommand) respond(ctx context.Context, slashCmd slack.SlashCommand) error {
+	fmt.Printf("Answering command %s %q from user %s\n", slashCmd.Command, slashCmd.Text, slashCmd.UserID)
+	return slack.PostWebhookContext(ctx, slashCmd.ResponseURL, &slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         cmd.answer(ctx, slashCmd.Text),
+	})
+}
+
+// runSocketMode connects to Slack via Socket Mode and answers /oncall
+// commands until the context is canceled.
+func runSocketMode(ctx context.Context, botToken, appToken string, cmd onCallCommand) error {
+	api := slack.New(botToken, slack.OptionAppLevelToken(appToken))
+	client := socketmode.New(api)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-client.Events:
+				if !ok {
+					return
+				}
+				switch evt.Type {
+				case socketmode.EventTypeConnected:
+					fmt.Println("Connected to Slack via Socket Mode")
+				case socketmode.EventTypeConnectionError:
+					fmt.Printf("Failed to connect to Slack via Socket Mode: %v\n", evt.Data)
+				case socketmode.EventTypeSlashCommand:
+					slashCmd, ok := evt.Data.(slack.SlashCommand)
+					if !ok {
+						continue
+					}
+					// Acknowledge right away since Slack expects commands to be
+					// acknowledged within three seconds, and answering may
+					// take longer.
+					client.Ack(*evt.Request)
+					go func() {
+						if err := cmd.respond(ctx, slashCmd); err != nil {
+							fmt.Printf("Failed to answer command %s from user %s: %s\n", slashCmd.Command, slashCmd.UserID, err)
+						}
+					}()
+				}
+			}
+		}
+	}()
+
+	return client.RunContext(ctx)
+}
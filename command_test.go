@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+func TestOnCallCommandAnswer(t *testing.T) {
+	provider := &fakeOnCallProvider{
+		users: map[string][]oncallUser{
+			"S1": {{id: "PD1", name: "Jane", email: "jane@example.com"}},
+			"S2": {{id: "PD2", name: "John", email: "john@example.com"}, {id: "PD3", name: "Max", email: "max@example.com"}},
+		},
+	}
+	primary := oncallSchedule{id: "S1", name: "Primary", provider: provider, userGroups: UserGroups{{ID: "G1", Handle: "oncall"}}}
+	secondary := oncallSchedule{id: "S2", name: "Secondary", provider: provider}
+	database := oncallSchedule{id: "S3", name: "Database", provider: provider}
+
+	cmd := onCallCommand{
+		slackSyncs: []runSlackSync{
+			{name: "team", schedules: oncallSchedules{primary, secondary}},
+			{name: "storage", schedules: oncallSchedules{primary, database}},
+		},
+		slackUsers: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "all schedules",
+			query: "",
+			want:  "*Primary*: <@U1> (<!subteam^G1|@oncall>)\n*Secondary*: <@U2>, Max\n*Database*: nobody",
+		},
+		{
+			name:  "sync name",
+			query: " Storage ",
+			want:  "*Primary*: <@U1> (<!subteam^G1|@oncall>)\n*Database*: nobody",
+		},
+		{
+			name:  "schedule name",
+			query: "secondary",
+			want:  "*Secondary*: <@U2>, Max",
+		},
+		{
+			name:  "unknown name",
+			query: "tertiary",
+			want:  `No sync or schedule named "tertiary". Try one of: team, Primary, Secondary, storage, Database`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cmd.answer(context.Background(), tt.query); got != tt.want {
+				t.Errorf("got answer %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnCallCommandRespond(t *testing.T) {
+	var got slack.WebhookMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode response: %s", err)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	provider := &fakeOnCallProvider{
+		users: map[string][]oncallUser{
+			"S1": {{id: "PD1", name: "Jane", email: "jane@example.com"}},
+		},
+	}
+	cmd := onCallCommand{
+		slackSyncs: []runSlackSync{
+			{name: "team", schedules: oncallSchedules{{id: "S1", name: "Primary", provider: provider}}},
+		},
+		slackUsers: slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+
+	slashCmd := slack.SlashCommand{Command: "/oncall", UserID: "U2", ResponseURL: srv.URL}
+	if err := cmd.respond(context.Background(), slashCmd); err != nil {
+		t.Fatalf("failed to respond: %s", err)
+	}
+
+	want := slack.WebhookMessage{ResponseType: slack.ResponseTypeEphemeral, Text: "*Primary*: <@U1>"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("response mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -4,22 +4,88 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
-// ConfigSchedule represents a PagerDuty schedule identified by either ID or name.
+// ConfigSchedule represents an on-call schedule identified by either ID or name.
 type ConfigSchedule struct {
 	ID         string     `yaml:"id"`
 	Name       string     `yaml:"name"`
 	UserGroups UserGroups `yaml:"userGroups"`
+	// ICS reads the schedule from an iCalendar feed instead of the sync's provider.
+	ICS *ConfigICS `yaml:"ics"`
+	// Rotation computes the schedule from a static rotation instead of reading it from the sync's provider.
+	Rotation *ConfigRotation `yaml:"rotation"`
+	// Status sets the Slack status of the on-call users.
+	Status *ConfigStatus `yaml:"status"`
+}
+
+// ConfigStatus represents a Slack status set for on-call users.
+type ConfigStatus struct {
+	// Text defaults to "On call for <schedule name>".
+	Text string `yaml:"text"`
+	// Emoji defaults to ":pager:".
+	Emoji string `yaml:"emoji"`
 }
 
 func (cs ConfigSchedule) String() string {
+	if cs.ICS != nil {
+		return fmt.Sprintf("{Name:%q ICS:%s}", cs.Name, cs.ICS)
+	}
+	if cs.Rotation != nil {
+		return fmt.Sprintf("{Name:%q Rotation:%s}", cs.Name, cs.Rotation)
+	}
 	return fmt.Sprintf("{ID:%s Name:%q}", cs.ID, cs.Name)
 }
 
+// ConfigICS represents an iCalendar feed given by either file or URL.
+type ConfigICS struct {
+	File string `yaml:"file"`
+	URL  string `yaml:"url"`
+	// SummaryPattern is a regular expression to extract the on-call user from the event summary. If empty, the event attendees are used.
+	SummaryPattern string `yaml:"summaryPattern"`
+}
+
+func (ci ConfigICS) String() string {
+	return fmt.Sprintf("{File:%s URL:%s SummaryPattern:%q}", ci.File, ci.URL, ci.SummaryPattern)
+}
+
+// ConfigEscalationPolicy represents a PagerDuty escalation policy identified by either ID or name.
+type ConfigEscalationPolicy struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Levels are the escalation levels whose on-call users should be synced. Defaults to the first level.
+	Levels     []uint     `yaml:"levels"`
+	UserGroups UserGroups `yaml:"userGroups"`
+}
+
+func (cep ConfigEscalationPolicy) String() string {
+	return fmt.Sprintf("{ID:%s Name:%q Levels:%v}", cep.ID, cep.Name, cep.Levels)
+}
+
+// ConfigRotation represents a rotation of members computed locally.
+type ConfigRotation struct {
+	// Members are the email addresses or names of the rotating users.
+	Members []string `yaml:"members"`
+	// Length is the duration of each shift.
+	Length time.Duration `yaml:"length"`
+	// HandoffTime is the time of day (HH:MM) at which shifts start. Defaults to midnight.
+	HandoffTime string `yaml:"handoffTime"`
+	// TimeZone is the IANA time zone of the handoff time. Defaults to UTC.
+	TimeZone string `yaml:"timeZone"`
+	// StartDate is the date (YYYY-MM-DD) on which the first member's shift starts.
+	StartDate string `yaml:"startDate"`
+}
+
+func (cr ConfigRotation) String() string {
+	return fmt.Sprintf("{Members:%v Length:%s HandoffTime:%s TimeZone:%s StartDate:%s}", cr.Members, cr.Length, cr.HandoffTime, cr.TimeZone, cr.StartDate)
+}
+
 type UserGroups []UserGroup
 
 func (ugs UserGroups) find(ug2 UserGroup) *UserGroup {
@@ -52,6 +118,16 @@ type UserGroup struct {
 	ID     string `yaml:"id"`
 	Name   string `yaml:"name"`
 	Handle string `yaml:"handle"`
+	// Create enables creating the user group if it does not exist, and
+	// re-enabling it if it is disabled.
+	Create      bool   `yaml:"create"`
+	Description string `yaml:"description"`
+	// DescriptionTemplate is the Go template for the description that is
+	// kept up-to-date with the on-call users.
+	DescriptionTemplate string `yaml:"descriptionTemplate"`
+	// TimeZone is the IANA time zone that the description template renders
+	// shift ends in. Defaults to UTC.
+	TimeZone string `yaml:"timeZone"`
 }
 
 func (ug UserGroup) String() string {
@@ -68,18 +144,214 @@ func (cc ConfigChannel) String() string {
 	return fmt.Sprintf("{ID:%s Name:%q}", cc.ID, cc.Name)
 }
 
-// ConfigSlackSync represents a synchronization between a set of PagerDuty schedules and a Slack channel.
+// ConfigSlackSync represents a synchronization between a set of on-call schedules and a Slack channel.
 type ConfigSlackSync struct {
-	Name         string           `yaml:"name"`
-	Schedules    []ConfigSchedule `yaml:"schedules"`
-	Channel      ConfigChannel    `yaml:"channel"`
-	Template     string           `yaml:"template"`
-	PretendUsers bool             `yaml:"pretendUsers"`
-	DryRun       bool             `yaml:"dryRun"`
+	Name string `yaml:"name"`
+	// Provider is the on-call provider to read schedules from, either "pagerduty" (the default) or "opsgenie".
+	Provider           string                   `yaml:"provider"`
+	Schedules          []ConfigSchedule         `yaml:"schedules"`
+	EscalationPolicies []ConfigEscalationPolicy `yaml:"escalationPolicies"`
+	Channel            ConfigChannel            `yaml:"channel"`
+	Template           string                   `yaml:"template"`
+	// TopicMarkers restricts the template output to the part of the topic between the markers.
+	TopicMarkers *ConfigTopicMarkers `yaml:"topicMarkers"`
+	// PurposeTemplate is the Go template for the channel purpose (also known as description).
+	PurposeTemplate   string                   `yaml:"purposeTemplate"`
+	PretendUsers      bool                     `yaml:"pretendUsers"`
+	DryRun            bool                     `yaml:"dryRun"`
+	Teams             *ConfigTeams             `yaml:"teams"`
+	Mattermost        *ConfigMattermost        `yaml:"mattermost"`
+	Discord           *ConfigDiscord           `yaml:"discord"`
+	Webhook           *ConfigWebhook           `yaml:"webhook"`
+	Email             *ConfigEmail             `yaml:"email"`
+	Announcement      *ConfigAnnouncement      `yaml:"announcement"`
+	Bookmark          *ConfigBookmark          `yaml:"bookmark"`
+	Reminder          *ConfigReminder          `yaml:"reminder"`
+	Roster            *ConfigRoster            `yaml:"roster"`
+	ChannelMembership *ConfigChannelMembership `yaml:"channelMembership"`
+	Canvas            *ConfigCanvas            `yaml:"canvas"`
+	// SlackConnection is the name of the Slack connection to use. Defaults to the connection given by flag.
+	SlackConnection string `yaml:"slackConnection"`
+}
+
+// usesProvider returns whether any schedules or escalation policies need to be read from the sync's provider.
+func (cs ConfigSlackSync) usesProvider() bool {
+	if len(cs.EscalationPolicies) > 0 {
+		return true
+	}
+	for _, cfgSchedule := range cs.Schedules {
+		if cfgSchedule.ICS == nil && cfgSchedule.Rotation == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigTeams represents a Microsoft Teams channel and tags to keep in sync.
+type ConfigTeams struct {
+	TeamID    string `yaml:"teamID"`
+	ChannelID string `yaml:"channelID"`
+	// Template is the Go template for the channel description. Defaults to the sync's template.
+	Template string           `yaml:"template"`
+	Tags     []ConfigTeamsTag `yaml:"tags"`
+}
+
+// ConfigTeamsTag represents a Microsoft Teams tag identified by either ID or name.
+type ConfigTeamsTag struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Schedules are the names of the schedules whose on-call users should be tag members. Defaults to all schedules.
+	Schedules []string `yaml:"schedules"`
+}
+
+func (ctt ConfigTeamsTag) String() string {
+	return fmt.Sprintf("{ID:%s Name:%q}", ctt.ID, ctt.Name)
+}
+
+// ConfigMattermost represents a Mattermost channel and custom groups to keep in sync.
+type ConfigMattermost struct {
+	Channel ConfigMattermostChannel `yaml:"channel"`
+	// Template is the Go template for the channel header. Defaults to the sync's template.
+	Template string                  `yaml:"template"`
+	Groups   []ConfigMattermostGroup `yaml:"groups"`
+}
+
+// ConfigMattermostChannel represents a Mattermost channel identified by either ID or team and channel name.
+type ConfigMattermostChannel struct {
+	ID   string `yaml:"id"`
+	Team string `yaml:"team"`
+	Name string `yaml:"name"`
+}
+
+func (cmc ConfigMattermostChannel) String() string {
+	return fmt.Sprintf("{ID:%s Team:%s Name:%q}", cmc.ID, cmc.Team, cmc.Name)
+}
+
+// ConfigMattermostGroup represents a Mattermost custom group identified by either ID or name (i.e., mention handle).
+type ConfigMattermostGroup struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+	// Schedules are the names of the schedules whose on-call users should be group members. Defaults to all schedules.
+	Schedules []string `yaml:"schedules"`
+}
+
+func (cmg ConfigMattermostGroup) String() string {
+	return fmt.Sprintf("{ID:%s Name:%q}", cmg.ID, cmg.Name)
+}
+
+// ConfigDiscord represents a Discord channel and role to keep in sync.
+type ConfigDiscord struct {
+	GuildID   string `yaml:"guildID"`
+	ChannelID string `yaml:"channelID"`
+	// Template is the Go template for the channel topic. Defaults to the sync's template.
+	Template string `yaml:"template"`
+	// RoleID is the role assigned to the on-call users.
+	RoleID string `yaml:"roleID"`
+	// Users maps on-call user IDs or emails to Discord user IDs.
+	Users map[string]string `yaml:"users"`
+}
+
+// ConfigWebhook represents an HTTP endpoint notified about on-call changes.
+type ConfigWebhook struct {
+	URL string `yaml:"url"`
+	// Secret is the key used to sign the request body with HMAC-SHA256. Signing is skipped if empty.
+	Secret string `yaml:"secret"`
+}
+
+// ConfigEmail represents handoff emails sent to users whose on-call status changes.
+type ConfigEmail struct {
+	// Subject is the Go template for the email subject.
+	Subject string `yaml:"subject"`
+	// Template is the Go template for the email body.
+	Template string `yaml:"template"`
+	// NotifyOutgoing determines whether users going off call are emailed as well.
+	NotifyOutgoing bool `yaml:"notifyOutgoing"`
+}
+
+// ConfigAnnouncement represents a message posted to Slack when the on-call users of a schedule change.
+type ConfigAnnouncement struct {
+	// Template is the Go template for the message.
+	Template string `yaml:"template"`
+	// Channel is the channel to post to. Defaults to the sync's channel.
+	Channel ConfigChannel `yaml:"channel"`
+}
+
+// ConfigBookmark represents a link bookmark in the sync's Slack channel.
+type ConfigBookmark struct {
+	// Title is the Go template for the bookmark title.
+	Title string `yaml:"title"`
+	// Link is the Go template for the bookmark link.
+	Link  string `yaml:"link"`
+	Emoji string `yaml:"emoji"`
+	// TimeZone is the IANA time zone that shift ends are rendered in. Defaults to UTC.
+	TimeZone string `yaml:"timeZone"`
+}
+
+// usesSlackStatus returns whether any schedule of the sync sets Slack statuses.
+func (cfgSlSync ConfigSlackSync) usesSlackStatus() bool {
+	for _, cfgSchedule := range cfgSlSync.Schedules {
+		if cfgSchedule.Status != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigReminder represents direct messages reminding users of their upcoming shifts.
+type ConfigReminder struct {
+	// Before is how long before the start of a shift the reminder is sent.
+	Before time.Duration `yaml:"before"`
+	// Template is the Go template for the message.
+	Template string `yaml:"template"`
+}
+
+// ConfigRoster represents a pinned message in the sync's Slack channel listing
+// the on-call users of each schedule.
+type ConfigRoster struct {
+	// Title is the header of the message. Defaults to "On-call roster".
+	Title string `yaml:"title"`
+}
+
+// ConfigCanvas represents the canvas of the sync's Slack channel rendered from a Markdown template.
+type ConfigCanvas struct {
+	// Template is the Go template for the Markdown content. Defaults to a page listing all schedules.
+	Template string `yaml:"template"`
+	// Lookahead is how far ahead upcoming shifts are listed. Defaults to 7 days.
+	Lookahead time.Duration `yaml:"lookahead"`
+	// TimeZone is the IANA time zone to render times in. Defaults to UTC.
+	TimeZone string `yaml:"timeZone"`
+	// TakeOver lets pdsync overwrite an existing channel canvas that it did
+	// not create.
+	TakeOver bool `yaml:"takeOver"`
+}
+
+// ConfigChannelMembership represents Slack channels that on-call users are invited to.
+type ConfigChannelMembership struct {
+	Channels []ConfigChannel `yaml:"channels"`
+	// RemovePrevious removes users that pdsync invited once they are no longer on call.
+	RemovePrevious bool `yaml:"removePrevious"`
+}
+
+// ConfigTopicMarkers delimits the region of the channel topic managed by pdsync.
+type ConfigTopicMarkers struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// ConfigSlackConnection represents a Slack workspace or Enterprise Grid organization that syncs can reference.
+type ConfigSlackConnection struct {
+	Name string `yaml:"name"`
+	// TokenEnvVar is the environment variable holding the Slack token.
+	TokenEnvVar string `yaml:"tokenEnvVar"`
+	// UserTokenEnvVar is the environment variable holding the Slack user token used to set user statuses.
+	UserTokenEnvVar string `yaml:"userTokenEnvVar"`
+	// TeamID is the ID of the workspace to scope requests to within an Enterprise Grid organization.
+	TeamID string `yaml:"teamID"`
 }
 
 type config struct {
-	SlackSyncs []ConfigSlackSync `yaml:"slackSyncs"`
+	SlackConnections []ConfigSlackConnection `yaml:"slackConnections"`
+	SlackSyncs       []ConfigSlackSync       `yaml:"slackSyncs"`
 }
 
 func generateConfig(p params) (config, error) {
@@ -107,6 +379,12 @@ func generateConfig(p params) (config, error) {
 		}
 	}
 
+	for i := range cfg.SlackSyncs {
+		if cfg.SlackSyncs[i].Provider == "" {
+			cfg.SlackSyncs[i].Provider = providerPagerDuty
+		}
+	}
+
 	// Let globally defined parameters override per-sync ones.
 
 	if p.pretendUsers != nil {
@@ -155,6 +433,13 @@ func singleSlackSync(p params) (config, error) {
 		}
 		slackSync.Schedules = append(slackSync.Schedules, cfgSchedule)
 	}
+	for _, escalationPolicy := range p.escalationPolicies {
+		cfgEscalationPolicy, err := parseEscalationPolicy(escalationPolicy)
+		if err != nil {
+			return config{}, err
+		}
+		slackSync.EscalationPolicies = append(slackSync.EscalationPolicies, cfgEscalationPolicy)
+	}
 
 	return config{
 		SlackSyncs: []ConfigSlackSync{slackSync},
@@ -162,47 +447,118 @@ func singleSlackSync(p params) (config, error) {
 }
 
 func parseSchedule(schedule string) (ConfigSchedule, error) {
+	kvs, err := parseKeyValues(schedule)
+	if err != nil {
+		return ConfigSchedule{}, err
+	}
+
+	id, name, err := parseReference(kvs)
+	if err != nil {
+		return ConfigSchedule{}, err
+	}
+
+	cfgSchedule := ConfigSchedule{
+		ID:   id,
+		Name: name,
+	}
+
+	cfgSchedule.UserGroups, err = parseUserGroups(kvs)
+	if err != nil {
+		return ConfigSchedule{}, err
+	}
+
+	if len(kvs) > 0 {
+		return ConfigSchedule{}, fmt.Errorf("unsupported key/value pairs left: %s", kvs)
+	}
+
+	return cfgSchedule, nil
+}
+
+func parseEscalationPolicy(escalationPolicy string) (ConfigEscalationPolicy, error) {
+	kvs, err := parseKeyValues(escalationPolicy)
+	if err != nil {
+		return ConfigEscalationPolicy{}, err
+	}
+
+	id, name, err := parseReference(kvs)
+	if err != nil {
+		return ConfigEscalationPolicy{}, err
+	}
+
+	cfgEscalationPolicy := ConfigEscalationPolicy{
+		ID:   id,
+		Name: name,
+	}
+
+	for _, level := range kvs["level"] {
+		lvl, err := strconv.ParseUint(level, 10, 0)
+		if err != nil {
+			return ConfigEscalationPolicy{}, fmt.Errorf("level %q is not a number: %s", level, err)
+		}
+		cfgEscalationPolicy.Levels = append(cfgEscalationPolicy.Levels, uint(lvl))
+	}
+	delete(kvs, "level")
+
+	cfgEscalationPolicy.UserGroups, err = parseUserGroups(kvs)
+	if err != nil {
+		return ConfigEscalationPolicy{}, err
+	}
+
+	if len(kvs) > 0 {
+		return ConfigEscalationPolicy{}, fmt.Errorf("unsupported key/value pairs left: %s", kvs)
+	}
+
+	return cfgEscalationPolicy, nil
+}
+
+func parseKeyValues(s string) (map[string][]string, error) {
 	kvs := map[string][]string{}
-	for _, elem := range strings.Split(schedule, ";") {
+	for _, elem := range strings.Split(s, ";") {
 		kv := strings.SplitN(elem, "=", 2)
 		if len(kv) < 2 {
-			return ConfigSchedule{}, fmt.Errorf("missing separator on element %q", elem)
+			return nil, fmt.Errorf("missing separator on element %q", elem)
 		}
 		key := kv[0]
 		value := kv[1]
 		kvs[key] = append(kvs[key], value)
 	}
 
-	var id string
+	return kvs, nil
+}
+
+// parseReference extracts and removes the mutually exclusive "id" and "name"
+// keys from the given key/value pairs.
+func parseReference(kvs map[string][]string) (id, name string, err error) {
 	if ids := kvs["id"]; len(ids) > 0 {
 		if len(ids) > 1 {
-			return ConfigSchedule{}, errors.New(`multiple values for key "id" not allowed`)
+			return "", "", errors.New(`multiple values for key "id" not allowed`)
 		}
 		id = ids[0]
 		delete(kvs, "id")
 	}
-	var name string
 	if names := kvs["name"]; len(names) > 0 {
 		if len(names) > 1 {
-			return ConfigSchedule{}, errors.New(`multiple values for key "name" not allowed`)
+			return "", "", errors.New(`multiple values for key "name" not allowed`)
 		}
 		name = names[0]
 		delete(kvs, "name")
 	}
 
 	if id != "" && name != "" {
-		return ConfigSchedule{}, errors.New(`"id" and "name" cannot be specified simultaneously`)
+		return "", "", errors.New(`"id" and "name" cannot be specified simultaneously`)
 	}
 
-	cfgSchedule := ConfigSchedule{
-		ID:   id,
-		Name: name,
-	}
+	return id, name, nil
+}
 
+// parseUserGroups extracts and removes the "userGroup" keys from the given
+// key/value pairs.
+func parseUserGroups(kvs map[string][]string) (UserGroups, error) {
+	var ugs UserGroups
 	for _, userGroup := range kvs["userGroup"] {
 		kv := strings.Split(userGroup, "=")
 		if len(kv) != 2 {
-			return ConfigSchedule{}, fmt.Errorf("user group %s does not follow key=value pattern", userGroup)
+			return nil, fmt.Errorf("user group %s does not follow key=value pattern", userGroup)
 		}
 		ugKey := kv[0]
 		ugValue := kv[1]
@@ -216,20 +572,31 @@ func parseSchedule(schedule string) (ConfigSchedule, error) {
 		case "handle":
 			ug.Handle = ugValue
 		default:
-			return ConfigSchedule{}, fmt.Errorf("user group %s has unexpected key %q", userGroup, ugKey)
+			return nil, fmt.Errorf("user group %s has unexpected key %q", userGroup, ugKey)
 		}
-		cfgSchedule.UserGroups = append(cfgSchedule.UserGroups, ug)
+		ugs = append(ugs, ug)
 	}
 	delete(kvs, "userGroup")
 
-	if len(kvs) > 0 {
-		return ConfigSchedule{}, fmt.Errorf("unsupported key/value pairs left: %s", kvs)
-	}
-
-	return cfgSchedule, nil
+	return ugs, nil
 }
 
 func validateConfig(cfg *config) error {
+	foundConnections := map[string]bool{}
+	for _, conn := range cfg.SlackConnections {
+		if conn.Name == "" {
+			return errors.New("slack connection invalid: must specify name")
+		}
+		if _, ok := foundConnections[conn.Name]; ok {
+			return fmt.Errorf("slack connection name %q already used", conn.Name)
+		}
+		foundConnections[conn.Name] = true
+
+		if conn.TokenEnvVar == "" {
+			return fmt.Errorf("slack connection %q invalid: must specify token environment variable", conn.Name)
+		}
+	}
+
 	foundNames := map[string]bool{}
 	for _, sync := range cfg.SlackSyncs {
 		if _, ok := foundNames[sync.Name]; ok {
@@ -237,24 +604,242 @@ func validateConfig(cfg *config) error {
 		}
 		foundNames[sync.Name] = true
 
+		if sync.SlackConnection != "" && !foundConnections[sync.SlackConnection] {
+			return fmt.Errorf("slack sync %q invalid: Slack connection %q not found", sync.Name, sync.SlackConnection)
+		}
+
 		for _, cfgSchedule := range sync.Schedules {
-			if cfgSchedule.ID == "" && cfgSchedule.Name == "" {
+			if cfgSchedule.ICS != nil && cfgSchedule.Rotation != nil {
+				return fmt.Errorf("slack sync %q schedule %s invalid: iCalendar feed and rotation cannot be specified simultaneously", sync.Name, cfgSchedule)
+			}
+			if cfgSchedule.ICS != nil {
+				if err := validateICS(sync.Name, cfgSchedule); err != nil {
+					return err
+				}
+			} else if cfgSchedule.Rotation != nil {
+				if err := validateRotation(sync.Name, cfgSchedule); err != nil {
+					return err
+				}
+			} else if cfgSchedule.ID == "" && cfgSchedule.Name == "" {
 				return fmt.Errorf("slack sync %q invalid: must specify either schedule ID or schedule name", sync.Name)
 			}
-			for _, cfgUserGroup := range cfgSchedule.UserGroups {
-				if cfgUserGroup.ID == "" && cfgUserGroup.Name == "" && cfgUserGroup.Handle == "" {
-					return fmt.Errorf("slack sync %q user group %s invalid: must specify either user group ID or user group name or user group handle", sync.Name, cfgUserGroup)
+			if err := validateUserGroups(sync.Name, cfgSchedule.UserGroups); err != nil {
+				return err
+			}
+		}
+
+		switch sync.Provider {
+		case providerPagerDuty, providerOpsgenie:
+		default:
+			return fmt.Errorf("slack sync %q invalid: unsupported provider %q", sync.Name, sync.Provider)
+		}
+
+		if len(sync.EscalationPolicies) > 0 && sync.Provider != providerPagerDuty {
+			return fmt.Errorf("slack sync %q invalid: escalation policies are only supported with provider %q", sync.Name, providerPagerDuty)
+		}
+		for _, cfgEscalationPolicy := range sync.EscalationPolicies {
+			if cfgEscalationPolicy.ID == "" && cfgEscalationPolicy.Name == "" {
+				return fmt.Errorf("slack sync %q invalid: must specify either escalation policy ID or escalation policy name", sync.Name)
+			}
+			for _, level := range cfgEscalationPolicy.Levels {
+				if level == 0 {
+					return fmt.Errorf("slack sync %q escalation policy %s invalid: escalation levels start at 1", sync.Name, cfgEscalationPolicy)
 				}
 			}
+			if err := validateUserGroups(sync.Name, cfgEscalationPolicy.UserGroups); err != nil {
+				return err
+			}
+		}
+
+		if sync.Teams != nil {
+			if err := validateTeams(sync); err != nil {
+				return err
+			}
+		}
+
+		if sync.Mattermost != nil {
+			if err := validateMattermost(sync); err != nil {
+				return err
+			}
+		}
+
+		if sync.Discord != nil {
+			if err := validateDiscord(sync); err != nil {
+				return err
+			}
+		}
+
+		if sync.Webhook != nil && sync.Webhook.URL == "" {
+			return fmt.Errorf("slack sync %q invalid: must specify webhook URL", sync.Name)
+		}
+
+		if sync.Email != nil && sync.Email.Template == "" {
+			return fmt.Errorf("slack sync %q invalid: must specify email template", sync.Name)
+		}
+
+		if sync.Announcement != nil {
+			if err := validateAnnouncement(sync); err != nil {
+				return err
+			}
+		}
+
+		if sync.TopicMarkers != nil {
+			if sync.Template == "" {
+				return fmt.Errorf("slack sync %q invalid: must specify template when topic markers are given", sync.Name)
+			}
+			if sync.TopicMarkers.Start == "" || sync.TopicMarkers.End == "" {
+				return fmt.Errorf("slack sync %q invalid: must specify start and end topic markers", sync.Name)
+			}
+			if strings.ContainsAny(sync.TopicMarkers.Start+sync.TopicMarkers.End, "<>&") {
+				return fmt.Errorf("slack sync %q invalid: topic markers must not contain any of the characters <, >, and & since Slack escapes them", sync.Name)
+			}
+		}
+
+		if sync.ChannelMembership != nil {
+			if err := validateChannelMembership(sync); err != nil {
+				return err
+			}
 		}
 
 		channelGiven := sync.Channel.ID != "" || sync.Channel.Name != ""
-		if sync.Template != "" {
+		if sync.Reminder != nil && sync.Reminder.Before <= 0 {
+			return fmt.Errorf("slack sync %q invalid: reminder duration must be positive", sync.Name)
+		}
+
+		if sync.Bookmark != nil && (sync.Bookmark.Title == "" || sync.Bookmark.Link == "") {
+			return fmt.Errorf("slack sync %q invalid: must specify bookmark title and link", sync.Name)
+		}
+
+		if sync.Canvas != nil && sync.Canvas.Lookahead < 0 {
+			return fmt.Errorf("slack sync %q invalid: canvas lookahead must not be negative", sync.Name)
+		}
+
+		if sync.Template != "" || sync.PurposeTemplate != "" || sync.Bookmark != nil || sync.Roster != nil || sync.Canvas != nil {
 			if !channelGiven {
-				return fmt.Errorf("slack sync %q invalid: must specify either channel ID or channel name when topic is given", sync.Name)
+				return fmt.Errorf("slack sync %q invalid: must specify either channel ID or channel name when topic, purpose, bookmark, roster, or canvas is given", sync.Name)
 			}
-		} else if channelGiven {
-			return fmt.Errorf("slack sync %q invalid: must specify template when either channel ID or channel name is given", sync.Name)
+		} else if channelGiven && sync.Announcement == nil {
+			return fmt.Errorf("slack sync %q invalid: must specify template or announcement when either channel ID or channel name is given", sync.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateUserGroups(syncName string, cfgUserGroups UserGroups) error {
+	for _, cfgUserGroup := range cfgUserGroups {
+		if cfgUserGroup.ID == "" && cfgUserGroup.Name == "" && cfgUserGroup.Handle == "" {
+			return fmt.Errorf("slack sync %q user group %s invalid: must specify either user group ID or user group name or user group handle", syncName, cfgUserGroup)
+		}
+		if cfgUserGroup.Create && (cfgUserGroup.Name == "" || cfgUserGroup.Handle == "") {
+			return fmt.Errorf("slack sync %q user group %s invalid: must specify user group name and handle when create is given", syncName, cfgUserGroup)
+		}
+	}
+
+	return nil
+}
+
+func validateICS(syncName string, cfgSchedule ConfigSchedule) error {
+	if cfgSchedule.Name == "" || cfgSchedule.ID != "" {
+		return fmt.Errorf("slack sync %q schedule %s invalid: iCalendar schedules must specify a name and no ID", syncName, cfgSchedule)
+	}
+	if (cfgSchedule.ICS.File == "") == (cfgSchedule.ICS.URL == "") {
+		return fmt.Errorf("slack sync %q schedule %s invalid: must specify either iCalendar file or URL", syncName, cfgSchedule)
+	}
+	if cfgSchedule.ICS.SummaryPattern != "" {
+		if _, err := regexp.Compile(cfgSchedule.ICS.SummaryPattern); err != nil {
+			return fmt.Errorf("slack sync %q schedule %s invalid: failed to parse summary pattern: %s", syncName, cfgSchedule, err)
+		}
+	}
+
+	return nil
+}
+
+func validateRotation(syncName string, cfgSchedule ConfigSchedule) error {
+	if cfgSchedule.Name == "" || cfgSchedule.ID != "" {
+		return fmt.Errorf("slack sync %q schedule %s invalid: rotations must specify a name and no ID", syncName, cfgSchedule)
+	}
+	if _, err := newStaticRotation(*cfgSchedule.Rotation); err != nil {
+		return fmt.Errorf("slack sync %q schedule %s invalid: %s", syncName, cfgSchedule, err)
+	}
+
+	return nil
+}
+
+func validateTeams(sync ConfigSlackSync) error {
+	if sync.Teams.TeamID == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify Microsoft Teams team ID", sync.Name)
+	}
+	if sync.Teams.ChannelID != "" && sync.Teams.Template == "" && sync.Template == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify template when Microsoft Teams channel ID is given", sync.Name)
+	}
+	for _, cfgTag := range sync.Teams.Tags {
+		if cfgTag.ID == "" && cfgTag.Name == "" {
+			return fmt.Errorf("slack sync %q Microsoft Teams tag %s invalid: must specify either tag ID or tag name", sync.Name, cfgTag)
+		}
+	}
+
+	return nil
+}
+
+func validateMattermost(sync ConfigSlackSync) error {
+	cfgChannel := sync.Mattermost.Channel
+	if cfgChannel.ID != "" && cfgChannel.Name != "" {
+		return fmt.Errorf("slack sync %q invalid: Mattermost channel ID and name cannot be specified simultaneously", sync.Name)
+	}
+	if cfgChannel.Name != "" && cfgChannel.Team == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify Mattermost team when channel name is given", sync.Name)
+	}
+	channelGiven := cfgChannel.ID != "" || cfgChannel.Name != ""
+	if channelGiven && sync.Mattermost.Template == "" && sync.Template == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify template when Mattermost channel is given", sync.Name)
+	}
+	for _, cfgGroup := range sync.Mattermost.Groups {
+		if cfgGroup.ID == "" && cfgGroup.Name == "" {
+			return fmt.Errorf("slack sync %q Mattermost custom group %s invalid: must specify either group ID or group name", sync.Name, cfgGroup)
+		}
+	}
+
+	return nil
+}
+
+func validateDiscord(sync ConfigSlackSync) error {
+	cfgDiscord := sync.Discord
+	if cfgDiscord.ChannelID == "" && cfgDiscord.RoleID == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify Discord channel ID and/or role ID", sync.Name)
+	}
+	if cfgDiscord.RoleID != "" && cfgDiscord.GuildID == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify Discord guild ID when role ID is given", sync.Name)
+	}
+	if cfgDiscord.ChannelID != "" && cfgDiscord.Template == "" && sync.Template == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify template when Discord channel ID is given", sync.Name)
+	}
+
+	return nil
+}
+
+func validateAnnouncement(sync ConfigSlackSync) error {
+	if sync.Announcement.Template == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify announcement template", sync.Name)
+	}
+	cfgChannel := sync.Announcement.Channel
+	if cfgChannel.ID != "" && cfgChannel.Name != "" {
+		return fmt.Errorf("slack sync %q invalid: announcement channel ID and channel name cannot be specified simultaneously", sync.Name)
+	}
+	if cfgChannel.ID == "" && cfgChannel.Name == "" && sync.Channel.ID == "" && sync.Channel.Name == "" {
+		return fmt.Errorf("slack sync %q invalid: must specify announcement channel when sync channel is not given", sync.Name)
+	}
+
+	return nil
+}
+
+func validateChannelMembership(sync ConfigSlackSync) error {
+	if len(sync.ChannelMembership.Channels) == 0 {
+		return fmt.Errorf("slack sync %q invalid: must specify at least one channel membership channel", sync.Name)
+	}
+	for _, cfgChannel := range sync.ChannelMembership.Channels {
+		if cfgChannel.ID == "" && cfgChannel.Name == "" {
+			return fmt.Errorf("slack sync %q invalid: must specify either channel ID or channel name for channel membership channel %s", sync.Name, cfgChannel)
 		}
 	}
 
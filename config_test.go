@@ -3,6 +3,7 @@ package main
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -86,3 +87,442 @@ func TestParseSchedule(t *testing.T) {
 		})
 	}
 }
+
+func TestParseEscalationPolicy(t *testing.T) {
+	tests := []struct {
+		name               string
+		inEscalationPolicy string
+		wantErrStr         string
+		wantCfg            ConfigEscalationPolicy
+	}{
+		{
+			name:               "id and name specifiers given",
+			inEscalationPolicy: "id=policy;name=policy",
+			wantErrStr:         `"id" and "name" cannot be specified simultaneously`,
+		},
+		{
+			name:               "non-numeric level",
+			inEscalationPolicy: "id=policy;level=first",
+			wantErrStr:         `level "first" is not a number`,
+		},
+		{
+			name:               "unsupported key/value pair",
+			inEscalationPolicy: "id=policy;foo=bar",
+			wantErrStr:         "unsupported key/value pairs left",
+		},
+		{
+			name:               "valid escalation policy with levels and user group",
+			inEscalationPolicy: "name=Team Awesome;level=1;level=2;userGroup=handle=my-ug",
+			wantCfg: ConfigEscalationPolicy{
+				Name:   "Team Awesome",
+				Levels: []uint{1, 2},
+				UserGroups: UserGroups{
+					{
+						Handle: "my-ug",
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCfg, err := parseEscalationPolicy(tt.inEscalationPolicy)
+			if tt.wantErrStr != "" {
+				var gotErrStr string
+				if err != nil {
+					gotErrStr = err.Error()
+				}
+				if !strings.Contains(gotErrStr, tt.wantErrStr) {
+					t.Errorf("got error string %q, want %q", gotErrStr, tt.wantErrStr)
+				}
+			} else if diff := cmp.Diff(tt.wantCfg, gotCfg); diff != "" {
+				t.Errorf("ConfigEscalationPolicy mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		inSync     ConfigSlackSync
+		wantErrStr string
+	}{
+		{
+			name: "Microsoft Teams without team ID",
+			inSync: ConfigSlackSync{
+				Teams: &ConfigTeams{ChannelID: "channel", Template: "{{mentions .Primary}}"},
+			},
+			wantErrStr: "must specify Microsoft Teams team ID",
+		},
+		{
+			name: "Microsoft Teams channel without template",
+			inSync: ConfigSlackSync{
+				Teams: &ConfigTeams{TeamID: "team", ChannelID: "channel"},
+			},
+			wantErrStr: "must specify template when Microsoft Teams channel ID is given",
+		},
+		{
+			name: "Microsoft Teams tag without ID or name",
+			inSync: ConfigSlackSync{
+				Teams: &ConfigTeams{TeamID: "team", Tags: []ConfigTeamsTag{{Schedules: []string{"Primary"}}}},
+			},
+			wantErrStr: "must specify either tag ID or tag name",
+		},
+		{
+			name: "Microsoft Teams channel with dedicated template",
+			inSync: ConfigSlackSync{
+				Teams: &ConfigTeams{TeamID: "team", ChannelID: "channel", Template: "{{mentions .Primary}}"},
+			},
+		},
+		{
+			name: "Microsoft Teams channel with sync template",
+			inSync: ConfigSlackSync{
+				Channel:  ConfigChannel{Name: "awesome"},
+				Template: "{{mentions .Primary}}",
+				Teams:    &ConfigTeams{TeamID: "team", ChannelID: "channel"},
+			},
+		},
+		{
+			name: "Mattermost channel ID and name",
+			inSync: ConfigSlackSync{
+				Mattermost: &ConfigMattermost{Channel: ConfigMattermostChannel{ID: "channel", Team: "team", Name: "awesome"}, Template: "{{mentions .Primary}}"},
+			},
+			wantErrStr: "Mattermost channel ID and name cannot be specified simultaneously",
+		},
+		{
+			name: "Mattermost channel name without team",
+			inSync: ConfigSlackSync{
+				Mattermost: &ConfigMattermost{Channel: ConfigMattermostChannel{Name: "awesome"}, Template: "{{mentions .Primary}}"},
+			},
+			wantErrStr: "must specify Mattermost team when channel name is given",
+		},
+		{
+			name: "Mattermost channel without template",
+			inSync: ConfigSlackSync{
+				Mattermost: &ConfigMattermost{Channel: ConfigMattermostChannel{ID: "channel"}},
+			},
+			wantErrStr: "must specify template when Mattermost channel is given",
+		},
+		{
+			name: "Mattermost group without ID or name",
+			inSync: ConfigSlackSync{
+				Mattermost: &ConfigMattermost{Groups: []ConfigMattermostGroup{{Schedules: []string{"Primary"}}}},
+			},
+			wantErrStr: "must specify either group ID or group name",
+		},
+		{
+			name: "Mattermost channel with sync template",
+			inSync: ConfigSlackSync{
+				Channel:    ConfigChannel{Name: "awesome"},
+				Template:   "{{mentions .Primary}}",
+				Mattermost: &ConfigMattermost{Channel: ConfigMattermostChannel{Team: "team", Name: "awesome"}},
+			},
+		},
+		{
+			name: "Discord without channel ID and role ID",
+			inSync: ConfigSlackSync{
+				Discord: &ConfigDiscord{GuildID: "guild"},
+			},
+			wantErrStr: "must specify Discord channel ID and/or role ID",
+		},
+		{
+			name: "Discord role without guild ID",
+			inSync: ConfigSlackSync{
+				Discord: &ConfigDiscord{RoleID: "role"},
+			},
+			wantErrStr: "must specify Discord guild ID when role ID is given",
+		},
+		{
+			name: "Discord channel without template",
+			inSync: ConfigSlackSync{
+				Discord: &ConfigDiscord{ChannelID: "channel"},
+			},
+			wantErrStr: "must specify template when Discord channel ID is given",
+		},
+		{
+			name: "Discord channel with sync template",
+			inSync: ConfigSlackSync{
+				Channel:  ConfigChannel{Name: "awesome"},
+				Template: "{{mentions .Primary}}",
+				Discord:  &ConfigDiscord{ChannelID: "channel"},
+			},
+		},
+		{
+			name: "webhook without URL",
+			inSync: ConfigSlackSync{
+				Webhook: &ConfigWebhook{Secret: "secret"},
+			},
+			wantErrStr: "must specify webhook URL",
+		},
+		{
+			name: "email without template",
+			inSync: ConfigSlackSync{
+				Email: &ConfigEmail{Subject: "Handoff"},
+			},
+			wantErrStr: "must specify email template",
+		},
+		{
+			name: "announcement without template",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				Announcement: &ConfigAnnouncement{},
+			},
+			wantErrStr: "must specify announcement template",
+		},
+		{
+			name: "announcement channel ID and name",
+			inSync: ConfigSlackSync{
+				Announcement: &ConfigAnnouncement{Template: "{{.Schedule}}", Channel: ConfigChannel{ID: "C1", Name: "awesome"}},
+			},
+			wantErrStr: "announcement channel ID and channel name cannot be specified simultaneously",
+		},
+		{
+			name: "announcement without any channel",
+			inSync: ConfigSlackSync{
+				Announcement: &ConfigAnnouncement{Template: "{{.Schedule}}"},
+			},
+			wantErrStr: "must specify announcement channel when sync channel is not given",
+		},
+		{
+			name: "announcement in sync channel without topic",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				Announcement: &ConfigAnnouncement{Template: "{{.Schedule}}"},
+			},
+		},
+		{
+			name: "purpose without channel",
+			inSync: ConfigSlackSync{
+				PurposeTemplate: "{{mentions .Primary}}",
+			},
+			wantErrStr: "must specify either channel ID or channel name when",
+		},
+		{
+			name: "purpose without topic",
+			inSync: ConfigSlackSync{
+				Channel:         ConfigChannel{Name: "awesome"},
+				PurposeTemplate: "{{mentions .Primary}}",
+			},
+		},
+		{
+			name: "bookmark without link",
+			inSync: ConfigSlackSync{
+				Channel:  ConfigChannel{Name: "awesome"},
+				Bookmark: &ConfigBookmark{Title: "On-call: {{.Primary}}"},
+			},
+			wantErrStr: "must specify bookmark title and link",
+		},
+		{
+			name: "bookmark without channel",
+			inSync: ConfigSlackSync{
+				Bookmark: &ConfigBookmark{Title: "On-call: {{.Primary}}", Link: "https://example.com"},
+			},
+			wantErrStr: "must specify either channel ID or channel name when",
+		},
+		{
+			name: "bookmark without topic",
+			inSync: ConfigSlackSync{
+				Channel:  ConfigChannel{Name: "awesome"},
+				Bookmark: &ConfigBookmark{Title: "On-call: {{.Primary}}", Link: "https://example.com"},
+			},
+		},
+		{
+			name: "reminder without duration",
+			inSync: ConfigSlackSync{
+				Reminder: &ConfigReminder{},
+			},
+			wantErrStr: "reminder duration must be positive",
+		},
+		{
+			name: "reminder without channel",
+			inSync: ConfigSlackSync{
+				Reminder: &ConfigReminder{Before: time.Hour},
+			},
+		},
+		{
+			name: "roster without channel",
+			inSync: ConfigSlackSync{
+				Roster: &ConfigRoster{},
+			},
+			wantErrStr: "must specify either channel ID or channel name when",
+		},
+		{
+			name: "roster without topic",
+			inSync: ConfigSlackSync{
+				Channel: ConfigChannel{Name: "awesome"},
+				Roster:  &ConfigRoster{},
+			},
+		},
+		{
+			name: "user group to create without handle",
+			inSync: ConfigSlackSync{
+				Schedules: []ConfigSchedule{{Name: "Primary", UserGroups: UserGroups{{Name: "Primary", Create: true}}}},
+			},
+			wantErrStr: "must specify user group name and handle when create is given",
+		},
+		{
+			name: "user group to create",
+			inSync: ConfigSlackSync{
+				Schedules: []ConfigSchedule{{Name: "Primary", UserGroups: UserGroups{{Name: "Primary", Handle: "primary", Create: true}}}},
+			},
+		},
+		{
+			name: "channel membership without channels",
+			inSync: ConfigSlackSync{
+				ChannelMembership: &ConfigChannelMembership{},
+			},
+			wantErrStr: "must specify at least one channel membership channel",
+		},
+		{
+			name: "channel membership channel without ID or name",
+			inSync: ConfigSlackSync{
+				ChannelMembership: &ConfigChannelMembership{Channels: []ConfigChannel{{}}},
+			},
+			wantErrStr: "must specify either channel ID or channel name for channel membership channel",
+		},
+		{
+			name: "channel membership",
+			inSync: ConfigSlackSync{
+				ChannelMembership: &ConfigChannelMembership{Channels: []ConfigChannel{{Name: "incidents"}}},
+			},
+		},
+		{
+			name: "topic markers without template",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				TopicMarkers: &ConfigTopicMarkers{Start: "[on-call]", End: "[/on-call]"},
+			},
+			wantErrStr: "must specify template when topic markers are given",
+		},
+		{
+			name: "topic markers without end",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				Template:     "{{mentions .Primary}}",
+				TopicMarkers: &ConfigTopicMarkers{Start: "[on-call]"},
+			},
+			wantErrStr: "must specify start and end topic markers",
+		},
+		{
+			name: "topic markers with escaped characters",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				Template:     "{{mentions .Primary}}",
+				TopicMarkers: &ConfigTopicMarkers{Start: "<on-call>", End: "</on-call>"},
+			},
+			wantErrStr: "topic markers must not contain any of the characters",
+		},
+		{
+			name: "topic markers",
+			inSync: ConfigSlackSync{
+				Channel:      ConfigChannel{Name: "awesome"},
+				Template:     "{{mentions .Primary}}",
+				TopicMarkers: &ConfigTopicMarkers{Start: "[on-call]", End: "[/on-call]"},
+			},
+		},
+		{
+			name: "canvas with negative lookahead",
+			inSync: ConfigSlackSync{
+				Channel: ConfigChannel{Name: "awesome"},
+				Canvas:  &ConfigCanvas{Lookahead: -time.Hour},
+			},
+			wantErrStr: "canvas lookahead must not be negative",
+		},
+		{
+			name: "canvas without channel",
+			inSync: ConfigSlackSync{
+				Canvas: &ConfigCanvas{},
+			},
+			wantErrStr: "must specify either channel ID or channel name when",
+		},
+		{
+			name: "canvas without topic",
+			inSync: ConfigSlackSync{
+				Channel: ConfigChannel{Name: "awesome"},
+				Canvas:  &ConfigCanvas{Lookahead: time.Hour},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sync := tt.inSync
+			sync.Name = "team"
+			sync.Provider = providerPagerDuty
+			err := validateConfig(&config{SlackSyncs: []ConfigSlackSync{sync}})
+			if tt.wantErrStr != "" {
+				var gotErrStr string
+				if err != nil {
+					gotErrStr = err.Error()
+				}
+				if !strings.Contains(gotErrStr, tt.wantErrStr) {
+					t.Errorf("got error string %q, want %q", gotErrStr, tt.wantErrStr)
+				}
+			} else if err != nil {
+				t.Errorf("got error %q, want none", err)
+			}
+		})
+	}
+}
+
+func TestValidateConfigSlackConnections(t *testing.T) {
+	tests := []struct {
+		name          string
+		inConnections []ConfigSlackConnection
+		inConnection  string
+		wantErrStr    string
+	}{
+		{
+			name:          "connection without name",
+			inConnections: []ConfigSlackConnection{{TokenEnvVar: "SLACK_TOKEN_OTHER"}},
+			wantErrStr:    "slack connection invalid: must specify name",
+		},
+		{
+			name: "duplicate connection name",
+			inConnections: []ConfigSlackConnection{
+				{Name: "other", TokenEnvVar: "SLACK_TOKEN_OTHER"},
+				{Name: "other", TokenEnvVar: "SLACK_TOKEN_OTHER2"},
+			},
+			wantErrStr: `slack connection name "other" already used`,
+		},
+		{
+			name:          "connection without token environment variable",
+			inConnections: []ConfigSlackConnection{{Name: "other"}},
+			wantErrStr:    "must specify token environment variable",
+		},
+		{
+			name:         "unknown connection",
+			inConnection: "other",
+			wantErrStr:   `Slack connection "other" not found`,
+		},
+		{
+			name:          "known connection",
+			inConnections: []ConfigSlackConnection{{Name: "other", TokenEnvVar: "SLACK_TOKEN_OTHER"}},
+			inConnection:  "other",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sync := ConfigSlackSync{
+				Name:            "team",
+				Provider:        providerPagerDuty,
+				SlackConnection: tt.inConnection,
+			}
+			err := validateConfig(&config{SlackConnections: tt.inConnections, SlackSyncs: []ConfigSlackSync{sync}})
+			if tt.wantErrStr != "" {
+				var gotErrStr string
+				if err != nil {
+					gotErrStr = err.Error()
+				}
+				if !strings.Contains(gotErrStr, tt.wantErrStr) {
+					t.Errorf("got error string %q, want %q", gotErrStr, tt.wantErrStr)
+				}
+			} else if err != nil {
+				t.Errorf("got error %q, want none", err)
+			}
+		})
+	}
+}
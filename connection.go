@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// defaultSlackConnection is the name of the Slack connection configured via
+// the slack-token and slack-user-token flags. It is used by all syncs that do
+// not reference a named connection.
+const defaultSlackConnection = ""
+
+// slackConnection holds the clients for a Slack workspace or Enterprise Grid
+// organization, along with the users, user groups, and channels cached from
+// it.
+type slackConnection struct {
+	name         string
+	client       *slackMetaClient
+	statusClient *slackStatusClient
+	users        slackUsers
+	userGroups   UserGroups
+	channels     channelList
+}
+
+// newSlackConnection creates the named Slack connection. Named connections
+// read their tokens from the configured environment variables, while the
+// default connection uses the tokens given by flag.
+func newSlackConnection(cfgConnections []ConfigSlackConnection, name string) (*slackConnection, error) {
+	if name == defaultSlackConnection {
+		if slToken == "" {
+			return nil, errors.New("Slack token must be given when syncs use the default Slack connection")
+		}
+		conn := &slackConnection{
+			name:   name,
+			client: newSlackMetaClient(slToken, "", includePrivateChannels),
+		}
+		if slUserToken != "" {
+			conn.statusClient = newSlackStatusClient(slUserToken)
+		}
+		return conn, nil
+	}
+
+	for _, cfgConnection := range cfgConnections {
+		if cfgConnection.Name != name {
+			continue
+		}
+
+		token := os.Getenv(cfgConnection.TokenEnvVar)
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %s holding the token of Slack connection %q is empty", cfgConnection.TokenEnvVar, name)
+		}
+		conn := &slackConnection{
+			name:   name,
+			client: newSlackMetaClient(token, cfgConnection.TeamID, includePrivateChannels),
+		}
+		if cfgConnection.UserTokenEnvVar != "" {
+			userToken := os.Getenv(cfgConnection.UserTokenEnvVar)
+			if userToken == "" {
+				return nil, fmt.Errorf("environment variable %s holding the user token of Slack connection %q is empty", cfgConnection.UserTokenEnvVar, name)
+			}
+			conn.statusClient = newSlackStatusClient(userToken)
+		}
+		return conn, nil
+	}
+
+	return nil, fmt.Errorf("Slack connection %q not found", name)
+}
+
+func (conn *slackConnection) String() string {
+	if conn.name == defaultSlackConnection {
+		return "default"
+	}
+	return conn.name
+}
+
+// load caches the users, user groups, and channels of the connection.
+func (conn *slackConnection) load(ctx context.Context) error {
+	var err error
+
+	fmt.Printf("Slack connection %s: getting Slack users\n", conn)
+	conn.users, err = conn.client.getSlackUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Slack users: %s", err)
+	}
+	fmt.Printf("Slack connection %s: found %d Slack user(s)\n", conn, len(conn.users))
+
+	fmt.Printf("Slack connection %s: getting Slack user groups\n", conn)
+	conn.userGroups, err = conn.client.getUserGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Slack user groups: %s", err)
+	}
+	fmt.Printf("Slack connection %s: found %d Slack user group(s)\n", conn, len(conn.userGroups))
+
+	fmt.Printf("Slack connection %s: getting Slack channels\n", conn)
+	conn.channels, err = conn.client.getChannels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get channels: %s", err)
+	}
+	fmt.Printf("Slack connection %s: found %d Slack channel(s)\n", conn, len(conn.channels))
+
+	return nil
+}
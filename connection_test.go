@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+func TestNewSlackConnection(t *testing.T) {
+	t.Setenv("GRID_TOKEN", "xoxb-grid")
+	cfgConnections := []ConfigSlackConnection{
+		{Name: "grid", TokenEnvVar: "GRID_TOKEN", TeamID: "T1"},
+		{Name: "unset", TokenEnvVar: "UNSET_TOKEN"},
+		{Name: "unset-user", TokenEnvVar: "GRID_TOKEN", UserTokenEnvVar: "UNSET_USER_TOKEN"},
+	}
+
+	tests := []struct {
+		name       string
+		connName   string
+		wantErrStr string
+		wantTeamID string
+	}{
+		{
+			name:       "named connection",
+			connName:   "grid",
+			wantTeamID: "T1",
+		},
+		{
+			name:       "unknown connection",
+			connName:   "missing",
+			wantErrStr: `Slack connection "missing" not found`,
+		},
+		{
+			name:       "empty token",
+			connName:   "unset",
+			wantErrStr: "environment variable UNSET_TOKEN",
+		},
+		{
+			name:       "empty user token",
+			connName:   "unset-user",
+			wantErrStr: "environment variable UNSET_USER_TOKEN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := newSlackConnection(cfgConnections, tt.connName)
+			if tt.wantErrStr != "" {
+				var gotErrStr string
+				if err != nil {
+					gotErrStr = err.Error()
+				}
+				if !strings.Contains(gotErrStr, tt.wantErrStr) {
+					t.Errorf("got error string %q, want %q", gotErrStr, tt.wantErrStr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create Slack connection: %s", err)
+			}
+			if conn.client.teamID != tt.wantTeamID {
+				t.Errorf("got team ID %q, want %q", conn.client.teamID, tt.wantTeamID)
+			}
+		})
+	}
+}
+
+func TestSlackConnectionLoad(t *testing.T) {
+	teamIDs := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		teamIDs[r.URL.Path] = r.Form.Get("team_id")
+
+		switch r.URL.Path {
+		case "/users.list":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":      true,
+				"members": []map[string]interface{}{{"id": "U1", "name": "jane", "profile": map[string]string{"email": "jane@example.com"}}},
+			})
+		case "/usergroups.list":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":         true,
+				"usergroups": []map[string]string{{"id": "G1", "name": "On-call", "handle": "oncall"}},
+			})
+		case "/conversations.list":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":       true,
+				"channels": []map[string]string{{"id": "C1", "name": "ops"}},
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		name: "grid",
+		client: &slackMetaClient{
+			slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/")),
+			teamID:      "T1",
+		},
+	}
+	if err := conn.load(context.Background()); err != nil {
+		t.Fatalf("failed to load Slack connection: %s", err)
+	}
+
+	wantTeamIDs := map[string]string{
+		"/users.list":         "T1",
+		"/usergroups.list":    "T1",
+		"/conversations.list": "T1",
+	}
+	if diff := cmp.Diff(wantTeamIDs, teamIDs); diff != "" {
+		t.Errorf("team IDs mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(slackUsers{{id: "U1", name: "jane", email: "jane@example.com"}}, conn.users, cmp.AllowUnexported(slackUser{})); diff != "" {
+		t.Errorf("users mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(UserGroups{{ID: "G1", Name: "On-call", Handle: "oncall"}}, conn.userGroups); diff != "" {
+		t.Errorf("user groups mismatch (-want +got):\n%s", diff)
+	}
+	if len(conn.channels) != 1 || conn.channels[0].ID != "C1" {
+		t.Errorf("got channels %v, want channel C1", conn.channels)
+	}
+}
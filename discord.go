@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultDiscordURL = "https://discord.com/api/v10"
+
+// discordUserIDs holds the Discord user IDs of all users on call for a
+// schedule. When rendered directly in a template, the IDs are joined by commas.
+type discordUserIDs []string
+
+func (ids discordUserIDs) String() string {
+	return strings.Join(ids, ",")
+}
+
+// discordTemplateFuncs mirrors templateFuncs for Discord.
+var discordTemplateFuncs = template.FuncMap{
+	"mentions": func(ids discordUserIDs) string {
+		userMentions := make([]string, 0, len(ids))
+		for _, id := range ids {
+			userMentions = append(userMentions, fmt.Sprintf("<@%s>", id))
+		}
+		return strings.Join(userMentions, ", ")
+	},
+}
+
+type runDiscordSync struct {
+	guildID   string
+	channelID string
+	tmpl      *template.Template
+	roleID    string
+	// users maps on-call user IDs and emails to Discord user IDs.
+	users map[string]string
+}
+
+// findUserID returns the Discord user ID mapped to the given on-call user,
+// trying the user ID first and the email second.
+func (ds runDiscordSync) findUserID(user oncallUser) (string, bool) {
+	if id, ok := ds.users[user.id]; ok {
+		return id, true
+	}
+	if user.email != "" {
+		if id, ok := ds.users[user.email]; ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func (sp syncerParams) createDiscordSync(ctx context.Context, slSyncName string, cfgSlSync ConfigSlackSync) (*runDiscordSync, error) {
+	cfgDiscord := cfgSlSync.Discord
+	if sp.discordClient == nil {
+		return nil, errors.New("Discord client is not configured")
+	}
+
+	discordSync := &runDiscordSync{
+		guildID:   cfgDiscord.GuildID,
+		channelID: cfgDiscord.ChannelID,
+		roleID:    cfgDiscord.RoleID,
+		users:     cfgDiscord.Users,
+	}
+
+	if cfgDiscord.ChannelID != "" {
+		tmplString := cfgDiscord.Template
+		if tmplString == "" {
+			tmplString = cfgSlSync.Template
+		}
+		var err error
+		discordSync.tmpl, err = template.New("topic").Funcs(discordTemplateFuncs).Parse(tmplString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %q: %s", tmplString, err)
+		}
+
+		channel, err := sp.discordClient.getChannel(ctx, cfgDiscord.ChannelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get channel %s: %s", cfgDiscord.ChannelID, err)
+		}
+		fmt.Printf("Slack sync %s: found Discord channel %q (ID %s)\n", slSyncName, channel.Name, channel.ID)
+	}
+
+	return discordSync, nil
+}
+
+func (s *syncer) runDiscordSync(ctx context.Context, discordSync runDiscordSync, onCalls []scheduleOnCall, dryRun bool) error {
+	userIDsByScheduleName := map[string]discordUserIDs{}
+	var allUserIDs []string
+	for _, onCall := range onCalls {
+		userIDs := discordUserIDs{}
+		for _, user := range onCall.users {
+			userID, ok := discordSync.findUserID(user)
+			if !ok {
+				return fmt.Errorf("failed to find Discord user mapping for on-call user %s", user)
+			}
+			userIDs = append(userIDs, userID)
+			allUserIDs = appendUnique(allUserIDs, userID)
+		}
+		userIDsByScheduleName[templateVarName(onCall.schedule.name)] = userIDs
+	}
+
+	if discordSync.roleID != "" {
+		if err := s.discordClient.updateRoleMembers(ctx, discordSync.guildID, discordSync.roleID, allUserIDs, dryRun); err != nil {
+			return fmt.Errorf("failed to update role members: %s", err)
+		}
+	}
+
+	if discordSync.tmpl == nil {
+		fmt.Println("Skipping Discord channel topic update")
+		return nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Printf("Executing Discord template with user IDs by schedule name: %s\n", userIDsByScheduleName)
+	if err := discordSync.tmpl.Execute(&buf, userIDsByScheduleName); err != nil {
+		return fmt.Errorf("failed to render template: %s", err)
+	}
+
+	if err := s.discordClient.updateTopic(ctx, discordSync.channelID, buf.String(), dryRun); err != nil {
+		return fmt.Errorf("failed to update channel topic: %s", err)
+	}
+
+	return nil
+}
+
+type discordChannel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Topic string `json:"topic"`
+}
+
+type discordMember struct {
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Roles []string `json:"roles"`
+}
+
+type discordClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+func newDiscordClient(token, baseURL string) *discordClient {
+	if baseURL == "" {
+		baseURL = defaultDiscordURL
+	}
+	return &discordClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+	}
+}
+
+func (cl *discordClient) getChannel(ctx context.Context, channelID string) (*discordChannel, error) {
+	var channel discordChannel
+	if err := cl.do(ctx, http.MethodGet, "/channels/"+url.PathEscape(channelID), nil, &channel); err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// getRoleMemberIDs returns the IDs of all guild members holding the given
+// role. Listing guild members requires the bot to have the privileged server
+// members intent enabled.
+func (cl *discordClient) getRoleMemberIDs(ctx context.Context, guildID, roleID string) ([]string, error) {
+	const limit = 1000
+
+	var memberIDs []string
+	after := "0"
+	for {
+		query := url.Values{
+			"limit": {fmt.Sprint(limit)},
+			"after": {after},
+		}
+		var members []discordMember
+		if err := cl.do(ctx, http.MethodGet, "/guilds/"+url.PathEscape(guildID)+"/members?"+query.Encode(), nil, &members); err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if containsString(member.Roles, roleID) {
+				memberIDs = append(memberIDs, member.User.ID)
+			}
+		}
+
+		if len(members) < limit {
+			break
+		}
+		after = members[len(members)-1].User.ID
+	}
+
+	return memberIDs, nil
+}
+
+func (cl *discordClient) updateRoleMembers(ctx context.Context, guildID, roleID string, userIDs []string, dryRun bool) error {
+	currentIDs, err := cl.getRoleMemberIDs(ctx, guildID, roleID)
+	if err != nil {
+		return fmt.Errorf("failed to get members of role %s: %s", roleID, err)
+	}
+
+	var toAdd, toRemove []string
+	for _, userID := range userIDs {
+		if !containsString(currentIDs, userID) {
+			toAdd = append(toAdd, userID)
+		}
+	}
+	for _, currentID := range currentIDs {
+		if !containsString(userIDs, currentID) {
+			toRemove = append(toRemove, currentID)
+		}
+	}
+
+	if len(toAdd) == 0 && len(toRemove) == 0 {
+		fmt.Printf("Role %s already has the right members\n", roleID)
+		return nil
+	}
+	concatMembers := strings.Join(userIDs, ",")
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not updating role %s with member(s): %s\n", roleID, concatMembers)
+		return nil
+	}
+
+	for _, userID := range toAdd {
+		if err := cl.do(ctx, http.MethodPut, cl.memberRolePath(guildID, userID, roleID), nil, nil); err != nil {
+			return fmt.Errorf("failed to add role %s to user %s: %s", roleID, userID, err)
+		}
+	}
+	for _, userID := range toRemove {
+		if err := cl.do(ctx, http.MethodDelete, cl.memberRolePath(guildID, userID, roleID), nil, nil); err != nil {
+			return fmt.Errorf("failed to remove role %s from user %s: %s", roleID, userID, err)
+		}
+	}
+	fmt.Printf("Updated role %s with member(s): %s\n", roleID, concatMembers)
+
+	return nil
+}
+
+func (cl *discordClient) memberRolePath(guildID, userID, roleID string) string {
+	return "/guilds/" + url.PathEscape(guildID) + "/members/" + url.PathEscape(userID) + "/roles/" + url.PathEscape(roleID)
+}
+
+func (cl *discordClient) updateTopic(ctx context.Context, channelID, topic string, dryRun bool) error {
+	channel, err := cl.getChannel(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if channel.Topic == topic {
+		fmt.Println("Discord channel topic already set correctly")
+		return nil
+	}
+
+	fmt.Printf("Updating Discord channel topic from\n[BEGIN-OF-OLD]\n%s\n[END-OF-OLD]\nto:\n[BEGIN-OF-NEW]\n%s\n[END-OF-NEW]\n", channel.Topic, topic)
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating Discord channel topic")
+		return nil
+	}
+	if err := cl.do(ctx, http.MethodPatch, "/channels/"+url.PathEscape(channelID), map[string]string{"topic": topic}, nil); err != nil {
+		return err
+	}
+	fmt.Println("Discord channel topic updated")
+
+	return nil
+}
+
+func (cl *discordClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bot "+cl.token)
+	return retryOnHTTPRateLimit("Discord", func() error {
+		return doJSONRequest(ctx, cl.httpClient, method, cl.baseURL+path, header, in, out)
+	})
+}
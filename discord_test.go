@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeDiscord is a minimal stand-in for the Discord API.
+type fakeDiscord struct {
+	topic string
+	roles map[string][]string // user ID -> role IDs
+}
+
+func (fd *fakeDiscord) roleMembers(roleID string) []string {
+	var userIDs []string
+	for userID, roles := range fd.roles {
+		if containsString(roles, roleID) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs
+}
+
+func (fd *fakeDiscord) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/channels/channel" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(discordChannel{ID: "channel", Topic: fd.topic})
+		case r.URL.Path == "/channels/channel" && r.Method == http.MethodPatch:
+			var req struct {
+				Topic string `json:"topic"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			fd.topic = req.Topic
+			_ = json.NewEncoder(w).Encode(discordChannel{ID: "channel", Topic: fd.topic})
+		case r.URL.Path == "/guilds/guild/members" && r.Method == http.MethodGet:
+			var members []discordMember
+			for userID, roles := range fd.roles {
+				var member discordMember
+				member.User.ID = userID
+				member.Roles = roles
+				members = append(members, member)
+			}
+			_ = json.NewEncoder(w).Encode(members)
+		case strings.HasPrefix(r.URL.Path, "/guilds/guild/members/"):
+			var userID, roleID string
+			if _, err := fmt.Sscanf(strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/guilds/guild/members/"), "/", " "), "%s roles %s", &userID, &roleID); err != nil {
+				t.Errorf("unexpected member path %s", r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var roles []string
+			for _, id := range fd.roles[userID] {
+				if id != roleID {
+					roles = append(roles, id)
+				}
+			}
+			if r.Method == http.MethodPut {
+				roles = append(roles, roleID)
+			}
+			fd.roles[userID] = roles
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRunDiscordSync(t *testing.T) {
+	fd := &fakeDiscord{
+		topic: "old topic",
+		roles: map[string][]string{
+			"discord-john": {"role-oncall"},
+			"discord-jane": {"role-other"},
+		},
+	}
+	srv := httptest.NewServer(fd.handler(t))
+	defer srv.Close()
+
+	s := newSyncer(syncerParams{
+		discordClient: newDiscordClient("token", srv.URL),
+	})
+	discordSync := runDiscordSync{
+		guildID:   "guild",
+		channelID: "channel",
+		tmpl:      template.Must(template.New("topic").Funcs(discordTemplateFuncs).Parse("on-call: {{mentions .Primary}}")),
+		roleID:    "role-oncall",
+		users: map[string]string{
+			"jane@example.com": "discord-jane",
+			"PD2":              "discord-john",
+		},
+	}
+	onCalls := []scheduleOnCall{
+		{
+			schedule: oncallSchedule{id: "sched-1", name: "Primary"},
+			users:    []oncallUser{{id: "PD1", name: "Jane Doe", email: "jane@example.com"}},
+		},
+	}
+
+	if err := s.runDiscordSync(context.Background(), discordSync, onCalls, true); err != nil {
+		t.Fatalf("failed to run dry-run Discord sync: %s", err)
+	}
+	if fd.topic != "old topic" {
+		t.Errorf("got topic %q in dry-run mode, want it unchanged", fd.topic)
+	}
+	if diff := cmp.Diff([]string{"discord-john"}, fd.roleMembers("role-oncall")); diff != "" {
+		t.Errorf("role members changed in dry-run mode (-want +got):\n%s", diff)
+	}
+
+	if err := s.runDiscordSync(context.Background(), discordSync, onCalls, false); err != nil {
+		t.Fatalf("failed to run Discord sync: %s", err)
+	}
+	if want := "on-call: <@discord-jane>"; fd.topic != want {
+		t.Errorf("got topic %q, want %q", fd.topic, want)
+	}
+	if diff := cmp.Diff([]string{"discord-jane"}, fd.roleMembers("role-oncall")); diff != "" {
+		t.Errorf("role members mismatch (-want +got):\n%s", diff)
+	}
+
+	onCalls[0].users = []oncallUser{{id: "PD3", name: "Unmapped"}}
+	if err := s.runDiscordSync(context.Background(), discordSync, onCalls, false); err == nil {
+		t.Error("got no error for unmapped on-call user")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultEmailSubject = `You are {{if .Incoming}}now{{else}}no longer{{end}} on call for {{.Schedule}}`
+
+type runEmailSync struct {
+	subjectTmpl    *template.Template
+	tmpl           *template.Template
+	notifyOutgoing bool
+}
+
+// emailTemplateData is passed to the email subject and body templates.
+type emailTemplateData struct {
+	SyncName string
+	Schedule string
+	// Recipient is the name of the user the email is sent to.
+	Recipient string
+	// Incoming is true if the recipient is starting their shift, and false if
+	// they are ending it.
+	Incoming      bool
+	IncomingUsers []string
+	OutgoingUsers []string
+}
+
+func (sp syncerParams) createEmailSync(cfgEmail ConfigEmail) (*runEmailSync, error) {
+	if sp.emailClient == nil {
+		return nil, errors.New("SMTP server is not configured")
+	}
+
+	subject := cfgEmail.Subject
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	subjectTmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subject template %q: %s", subject, err)
+	}
+	tmpl, err := template.New("email").Parse(cfgEmail.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %s", cfgEmail.Template, err)
+	}
+
+	return &runEmailSync{
+		subjectTmpl:    subjectTmpl,
+		tmpl:           tmpl,
+		notifyOutgoing: cfgEmail.NotifyOutgoing,
+	}, nil
+}
+
+// runEmailSync emails the users whose on-call status changed since the
+// previous run. Nothing is sent while the previous on-calls are unknown.
+// Recipients are tracked until all emails of a handoff were sent so that a
+// failed delivery is retried for the affected recipients only.
+func (s *syncer) runEmailSync(emailSync runEmailSync, slSyncName string, prevOnCalls, onCalls []scheduleOnCall, dryRun bool) error {
+	if prevOnCalls == nil {
+		fmt.Println("Previous on-call users unknown, not sending handoff emails")
+		return nil
+	}
+
+	var errs []error
+	for _, onCall := range onCalls {
+		prevUsers, ok := findOnCallUsers(prevOnCalls, onCall.schedule.id)
+		if !ok || sameOnCallUsers(prevUsers, onCall.users) {
+			continue
+		}
+
+		incoming := subtractOnCallUsers(onCall.users, prevUsers)
+		outgoing := subtractOnCallUsers(prevUsers, onCall.users)
+		data := emailTemplateData{
+			SyncName:      slSyncName,
+			Schedule:      onCall.schedule.name,
+			IncomingUsers: onCallUserNames(incoming),
+			OutgoingUsers: onCallUserNames(outgoing),
+		}
+
+		var recipients []oncallUser
+		recipients = append(recipients, incoming...)
+		if emailSync.notifyOutgoing {
+			recipients = append(recipients, outgoing...)
+		}
+		for _, user := range recipients {
+			if user.email == "" {
+				fmt.Printf("Not sending handoff email to on-call user without email %s\n", user)
+				continue
+			}
+			data.Recipient = user.name
+			data.Incoming = containsOnCallUser(incoming, user)
+
+			var subject, body bytes.Buffer
+			if err := emailSync.subjectTmpl.Execute(&subject, data); err != nil {
+				return fmt.Errorf("failed to render subject template: %s", err)
+			}
+			if err := emailSync.tmpl.Execute(&body, data); err != nil {
+				return fmt.Errorf("failed to render template: %s", err)
+			}
+
+			key := fmt.Sprintf("%s/%s", onCall.schedule.id, user.email)
+			if containsString(s.handoffEmails[slSyncName], key) {
+				fmt.Printf("Handoff email %q already sent to %s\n", subject.String(), user.email)
+				continue
+			}
+			if dryRun {
+				fmt.Printf("[DRY RUN] Not sending handoff email %q to %s\n", subject.String(), user.email)
+				continue
+			}
+			if err := s.emailClient.send(user.email, subject.String(), body.String()); err != nil {
+				errs = append(errs, fmt.Errorf("failed to send handoff email to %s: %s", user.email, err))
+				continue
+			}
+			s.handoffEmails[slSyncName] = append(s.handoffEmails[slSyncName], key)
+			fmt.Printf("Sent handoff email %q to %s\n", subject.String(), user.email)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	delete(s.handoffEmails, slSyncName)
+
+	return nil
+}
+
+// subtractOnCallUsers returns the users from a that are not in b.
+func subtractOnCallUsers(a, b []oncallUser) []oncallUser {
+	var diff []oncallUser
+	for _, user := range a {
+		if !containsOnCallUser(b, user) {
+			diff = append(diff, user)
+		}
+	}
+	return diff
+}
+
+func onCallUserNames(users []oncallUser) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.name)
+	}
+	return names
+}
+
+type emailClient struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+func newEmailClient(addr, from, username, password string) *emailClient {
+	return &emailClient{
+		addr:     addr,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+func (cl *emailClient) send(to, subject, body string) error {
+	var auth smtp.Auth
+	if cl.username != "" {
+		host, _, err := net.SplitHostPort(cl.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address %q: %s", cl.addr, err)
+		}
+		auth = smtp.PlainAuth("", cl.username, cl.password, host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cl.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+
+	return smtp.SendMail(cl.addr, auth, cl.from, []string{to}, []byte(msg.String()))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeSMTPMessage struct {
+	to   string
+	data string
+}
+
+// fakeSMTPServer is a minimal SMTP stand-in accepting all messages except
+// those to rejected recipients.
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []fakeSMTPMessage
+	rejected map[string]bool
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	srv := &fakeSMTPServer{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	_ = tc.PrintfLine("220 localhost ESMTP")
+
+	var msg fakeSMTPMessage
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = tc.PrintfLine("250 localhost")
+		case "MAIL":
+			_ = tc.PrintfLine("250 OK")
+		case "RCPT":
+			msg.to = strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			srv.mu.Lock()
+			rejected := srv.rejected[msg.to]
+			srv.mu.Unlock()
+			if rejected {
+				_ = tc.PrintfLine("550 Mailbox unavailable")
+				continue
+			}
+			_ = tc.PrintfLine("250 OK")
+		case "DATA":
+			_ = tc.PrintfLine("354 Go ahead")
+			lines, err := tc.ReadDotLines()
+			if err != nil {
+				return
+			}
+			msg.data = strings.Join(lines, "\n")
+			srv.mu.Lock()
+			srv.messages = append(srv.messages, msg)
+			srv.mu.Unlock()
+			_ = tc.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tc.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tc.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// body returns the message body without headers.
+func (msg fakeSMTPMessage) body() string {
+	parts := strings.SplitN(msg.data, "\n\n", 2)
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+func TestRunEmailSync(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	defer srv.listener.Close()
+
+	s := newSyncer(syncerParams{
+		emailClient: newEmailClient(srv.listener.Addr().String(), "pdsync@example.com", "", ""),
+	})
+	emailSync, err := s.createEmailSync(ConfigEmail{
+		Template:       `Hi {{.Recipient}}, {{if .Incoming}}take over from {{index .OutgoingUsers 0}}{{else}}hand over to {{index .IncomingUsers 0}}{{end}}.`,
+		NotifyOutgoing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create email sync: %s", err)
+	}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	onCallsJane := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}}}
+	onCallsJohn := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{john}}}
+
+	for _, run := range []struct {
+		prev   []scheduleOnCall
+		cur    []scheduleOnCall
+		dryRun bool
+	}{
+		{prev: nil, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJohn, dryRun: true},
+		{prev: onCallsJane, cur: onCallsJohn},
+	} {
+		if err := s.runEmailSync(*emailSync, "team", run.prev, run.cur, run.dryRun); err != nil {
+			t.Fatalf("failed to run email sync: %s", err)
+		}
+	}
+
+	var got []string
+	for _, msg := range srv.messages {
+		got = append(got, fmt.Sprintf("%s: %s", msg.to, msg.body()))
+	}
+	want := []string{
+		"john@example.com: Hi John Doe, take over from Jane Doe.",
+		"jane@example.com: Hi Jane Doe, hand over to John Doe.",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%s", diff)
+	}
+	if len(srv.messages) > 0 && !strings.Contains(srv.messages[0].data, "Subject: You are now on call for Primary") {
+		t.Errorf("got message without expected subject:\n%s", srv.messages[0].data)
+	}
+}
+
+func TestRunEmailSyncRetriesFailedRecipients(t *testing.T) {
+	srv := newFakeSMTPServer(t)
+	defer srv.listener.Close()
+	srv.rejected = map[string]bool{"jane@example.com": true}
+
+	s := newSyncer(syncerParams{
+		emailClient: newEmailClient(srv.listener.Addr().String(), "pdsync@example.com", "", ""),
+	})
+	emailSync, err := s.createEmailSync(ConfigEmail{
+		Template:       "Hi {{.Recipient}}",
+		NotifyOutgoing: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create email sync: %s", err)
+	}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	onCallsJane := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}}}
+	onCallsJohn := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{john}}}
+
+	if err := s.runEmailSync(*emailSync, "team", onCallsJane, onCallsJohn, false); err == nil {
+		t.Fatal("got no error for rejected recipient, want one")
+	}
+
+	srv.mu.Lock()
+	srv.rejected = nil
+	srv.mu.Unlock()
+	if err := s.runEmailSync(*emailSync, "team", onCallsJane, onCallsJohn, false); err != nil {
+		t.Fatalf("failed to retry email sync: %s", err)
+	}
+	if len(s.handoffEmails) != 0 {
+		t.Errorf("got handoff emails %v after complete delivery, want none", s.handoffEmails)
+	}
+
+	var got []string
+	for _, msg := range srv.messages {
+		got = append(got, msg.to)
+	}
+	want := []string{"john@example.com", "jane@example.com"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("recipients mismatch (-want +got):\n%s", diff)
+	}
+}
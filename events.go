@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// maxEventSize limits the size of Events API requests read.
+const maxEventSize = 1 << 20
+
+var onCallQuestionRE = regexp.MustCompile(`(?i)on[- ]?call`)
+
+// eventsHandler serves Slack's Events API and answers app mentions asking
+// who is on call with the on-call state of the syncs bound to the channel.
+type eventsHandler struct {
+	signingSecret string
+	slClient      *slackMetaClient
+	cmd           onCallCommand
+}
+
+func newEventsHandler(signingSecret string, slClient *slackMetaClient, cmd onCallCommand) *eventsHandler {
+	return &eventsHandler{
+		signingSecret: signingSecret,
+		slClient:      slClient,
+		cmd:           cmd,
+	}
+}
+
+func (h *eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sv, err := slack.NewSecretsVerifier(r.Header, h.signingSecret)
+	if err != nil {
+		fmt.Printf("Rejecting event request: %s\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if _, err := sv.Write(body); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := sv.Ensure(); err != nil {
+		fmt.Printf("Rejecting event request: %s\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		fmt.Printf("Failed to parse event: %s\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(challenge.Challenge))
+	case slackevents.CallbackEvent:
+		w.WriteHeader(http.StatusOK)
+		// Slack retries events that are not acknowledged in time; the
+		// original delivery is answered already.
+		if r.Header.Get("X-Slack-Retry-Num") != "" {
+			return
+		}
+		mention, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent)
+		if !ok || mention.BotID != "" {
+			return
+		}
+		// Reply asynchronously since Slack expects events to be acknowledged
+		// within three seconds.
+		go func() {
+			if err := h.reply(context.WithoutCancel(r.Context()), mention); err != nil {
+				fmt.Printf("Failed to answer mention in channel %s: %s\n", mention.Channel, err)
+			}
+		}()
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h *eventsHandler) reply(ctx context.Context, mention *slackevents.AppMentionEvent) error {
+	fmt.Printf("Answering mention %q from user %s in channel %s\n", mention.Text, mention.User, mention.Channel)
+	text := `Ask me "who is on call?"`
+	if onCallQuestionRE.MatchString(mention.Text) {
+		text = h.cmd.answerChannel(ctx, mention.Channel)
+	}
+	return h.slClient.postMessage(ctx, mention.Channel, text, false)
+}
+
+// runEventsServer serves the Events API on the given address until the
+// context is canceled.
+func runEventsServer(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Serving Slack events on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
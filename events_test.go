@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const testSigningSecret = "secret"
+
+func signedEventRequest(t *testing.T, secret, body string) *http.Request {
+	t.Helper()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestEventsHandlerVerification(t *testing.T) {
+	h := newEventsHandler(testSigningSecret, nil, onCallCommand{})
+
+	tests := []struct {
+		name     string
+		secret   string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "valid signature",
+			secret:   testSigningSecret,
+			wantCode: http.StatusOK,
+			wantBody: "challenge-token",
+		},
+		{
+			name:     "invalid signature",
+			secret:   "wrong",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, signedEventRequest(t, tt.secret, `{"type": "url_verification", "token": "tok", "challenge": "challenge-token"}`))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("got status code %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("got body %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEventsHandlerAppMention(t *testing.T) {
+	type message struct {
+		channel string
+		text    string
+	}
+	messages := make(chan message, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": r.PostForm.Get("channel"), "ts": "1.0"})
+		messages <- message{channel: r.PostForm.Get("channel"), text: r.PostForm.Get("text")}
+	}))
+	defer srv.Close()
+
+	provider := &fakeOnCallProvider{
+		users: map[string][]oncallUser{
+			"S1": {{id: "PD1", email: "jane@example.com"}},
+			"S2": {{id: "PD2", email: "john@example.com"}},
+		},
+	}
+	cmd := onCallCommand{
+		slackSyncs: []runSlackSync{
+			{name: "team", slackChannelID: "C1", schedules: oncallSchedules{{id: "S1", name: "Primary", provider: provider}}},
+			{name: "other", slackChannelID: "C2", schedules: oncallSchedules{{id: "S2", name: "Other", provider: provider}}},
+		},
+		slackUsers: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	h := newEventsHandler(testSigningSecret, &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))}, cmd)
+
+	tests := []struct {
+		name        string
+		text        string
+		wantMessage message
+	}{
+		{
+			name:        "on-call question",
+			text:        "<@UBOT> who is on call?",
+			wantMessage: message{channel: "C1", text: "*Primary*: <@U1>"},
+		},
+		{
+			name:        "other question",
+			text:        "<@UBOT> hello",
+			wantMessage: message{channel: "C1", text: `Ask me "who is on call?"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"type": "event_callback", "token": "tok", "event": {"type": "app_mention", "user": "U9", "text": %q, "channel": "C1", "ts": "1.0"}}`, tt.text)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, signedEventRequest(t, testSigningSecret, body))
+
+			resp := rec.Result()
+			if resp.StatusCode != http.StatusOK {
+				b, _ := io.ReadAll(resp.Body)
+				t.Fatalf("got status code %d (body: %s), want %d", resp.StatusCode, b, http.StatusOK)
+			}
+
+			select {
+			case got := <-messages:
+				if got != tt.wantMessage {
+					t.Errorf("got message %+v, want %+v", got, tt.wantMessage)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for reply")
+			}
+		})
+	}
+}
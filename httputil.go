@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/matryer/try"
+)
+
+// httpStatusError is returned for unexpected HTTP response codes.
+type httpStatusError struct {
+	statusCode int
+	// retryAfter is the delay requested by the server on rate-limited
+	// responses, if any.
+	retryAfter time.Duration
+	body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP response code: %d, body: %s", e.statusCode, e.body)
+}
+
+func isHTTPStatus(err error, statusCode int) bool {
+	var se *httpStatusError
+	return errors.As(err, &se) && se.statusCode == statusCode
+}
+
+// doJSONRequest sends a request with in encoded as JSON body (unless nil) and
+// decodes the JSON response body into out (unless nil). Responses with non-2xx
+// codes are returned as *httpStatusError.
+func doJSONRequest(ctx context.Context, httpClient *http.Client, method, url string, header http.Header, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %s", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return decodeJSONResponse(httpClient, req, out)
+}
+
+// decodeJSONResponse sends the given request and decodes the JSON response
+// body into out (unless nil). Responses with non-2xx codes are returned as
+// *httpStatusError.
+func decodeJSONResponse(httpClient *http.Client, req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		se := &httpStatusError{
+			statusCode: resp.StatusCode,
+			body:       string(respBody),
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			se.retryAfter = time.Duration(secs) * time.Second
+		}
+		return se
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %s", err)
+	}
+
+	return nil
+}
+
+func retryOnHTTPRateLimit(service string, f func() error) error {
+	return try.Do(func(attempt int) (retry bool, retryErr error) {
+		err := f()
+		if err != nil {
+			var se *httpStatusError
+			if errors.As(err, &se) && se.statusCode == http.StatusTooManyRequests {
+				sleep := se.retryAfter
+				if sleep == 0 {
+					sleep = 1 * time.Minute
+				}
+				fmt.Printf("%s rate limit hit -- waiting %s\n", service, sleep)
+				time.Sleep(sleep)
+				return true, err
+			}
+		}
+		return false, err
+	})
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// icsCalendar is an oncallProvider implementation reading on-call shifts from
+// an iCalendar feed. Every feed represents a single schedule.
+type icsCalendar struct {
+	httpClient *http.Client
+	file       string
+	url        string
+	// summaryRE extracts the on-call user from the event summary. If nil, the
+	// event attendees are used.
+	summaryRE *regexp.Regexp
+}
+
+func newICSCalendar(cfg ConfigICS) (*icsCalendar, error) {
+	cal := &icsCalendar{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		file:       cfg.File,
+		url:        cfg.URL,
+	}
+
+	if cfg.SummaryPattern != "" {
+		var err error
+		cal.summaryRE, err = regexp.Compile(cfg.SummaryPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse summary pattern %q: %s", cfg.SummaryPattern, err)
+		}
+	}
+
+	return cal, nil
+}
+
+func (cal *icsCalendar) source() string {
+	if cal.file != "" {
+		return cal.file
+	}
+	return cal.url
+}
+
+// getSchedule returns a schedule for the feed. Since feeds do not carry a
+// meaningful identifier, the ID is derived from the feed source and the
+// summary pattern: the same feed may back several schedules that extract
+// different users.
+func (cal *icsCalendar) getSchedule(ctx context.Context, id, name string) (*oncallSchedule, error) {
+	schedID := cal.source()
+	if cal.summaryRE != nil {
+		schedID += "#" + cal.summaryRE.String()
+	}
+	return &oncallSchedule{
+		id:   schedID,
+		name: name,
+	}, nil
+}
+
+func (cal *icsCalendar) getOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]oncallUser, error) {
+	fmt.Printf("Getting on-call users for iCalendar schedule %s\n", schedule)
+	rc, err := cal.open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open iCalendar feed %s: %s", cal.source(), err)
+	}
+	defer rc.Close()
+
+	events, err := parseICSEvents(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse iCalendar feed %s: %s", cal.source(), err)
+	}
+
+	onCallUsers := cal.onCallUsersAt(events, time.Now())
+	if len(onCallUsers) == 0 {
+		fmt.Printf("Got no on-call users for schedule %s\n", schedule)
+	}
+	for _, onCallUser := range onCallUsers {
+		fmt.Printf("Got on-call user %q (email %s) for schedule %s\n", onCallUser.name, onCallUser.email, schedule)
+	}
+
+	return onCallUsers, nil
+}
+
+func (cal *icsCalendar) open(ctx context.Context) (io.ReadCloser, error) {
+	if cal.file != "" {
+		return os.Open(cal.file)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cal.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cal.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP response code: %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// onCallUsersAt returns the users of all events active at the given time.
+func (cal *icsCalendar) onCallUsersAt(events []icsEvent, now time.Time) []oncallUser {
+	var onCallUsers []oncallUser
+	for _, event := range events {
+		if now.Before(event.start) || !now.Before(event.end) {
+			continue
+		}
+
+		if cal.summaryRE == nil {
+			for _, user := range event.attendees {
+				user.until = event.end
+				onCallUsers = append(onCallUsers, user)
+			}
+			continue
+		}
+
+		user, ok := userFromSummary(cal.summaryRE, event.summary)
+		if ok {
+			user.until = event.end
+			onCallUsers = append(onCallUsers, user)
+		}
+	}
+
+	return onCallUsers
+}
+
+// userFromSummary extracts a user from an event summary. Named capture groups
+// "email" and "name" are used if present; otherwise, the first capture group
+// is taken as email address if it contains an @ sign, and as name if not.
+func userFromSummary(re *regexp.Regexp, summary string) (oncallUser, bool) {
+	matches := re.FindStringSubmatch(summary)
+	if matches == nil {
+		return oncallUser{}, false
+	}
+
+	var user oncallUser
+	for i, groupName := range re.SubexpNames() {
+		switch groupName {
+		case "email":
+			user.email = matches[i]
+		case "name":
+			user.name = matches[i]
+		}
+	}
+
+	if user.email == "" && user.name == "" && len(matches) > 1 {
+		if strings.Contains(matches[1], "@") {
+			user.email = matches[1]
+		} else {
+			user.name = matches[1]
+		}
+	}
+
+	if user.email == "" && user.name == "" {
+		return oncallUser{}, false
+	}
+
+	user.id = user.email
+	if user.id == "" {
+		user.id = user.name
+	}
+
+	return user, true
+}
+
+type icsEvent struct {
+	summary   string
+	start     time.Time
+	end       time.Time
+	attendees []oncallUser
+}
+
+// icsProperty is a single content line of an iCalendar feed.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parseICSEvents parses the VEVENT components of an iCalendar feed. Recurrence
+// rules are not expanded, so feeds should list every shift as a separate event.
+func parseICSEvents(r io.Reader) ([]icsEvent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events  []icsEvent
+		current *icsEvent
+		// duration is used to compute the end time if DTEND is missing.
+		duration time.Duration
+	)
+	for _, line := range lines {
+		prop, ok := parseICSProperty(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case prop.name == "BEGIN" && prop.value == "VEVENT":
+			current = &icsEvent{}
+			duration = 0
+			continue
+		case prop.name == "END" && prop.value == "VEVENT":
+			if current == nil {
+				return nil, fmt.Errorf("unexpected %q", line)
+			}
+			if current.end.IsZero() {
+				current.end = current.start.Add(duration)
+			}
+			events = append(events, *current)
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch prop.name {
+		case "SUMMARY":
+			current.summary = unescapeICSText(prop.value)
+		case "DTSTART":
+			current.start, err = parseICSTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DTSTART %q: %s", prop.value, err)
+			}
+		case "DTEND":
+			current.end, err = parseICSTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DTEND %q: %s", prop.value, err)
+			}
+		case "DURATION":
+			duration, err = parseICSDuration(prop.value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse DURATION %q: %s", prop.value, err)
+			}
+		case "ATTENDEE":
+			email := prop.value
+			if len(email) >= len("mailto:") && strings.EqualFold(email[:len("mailto:")], "mailto:") {
+				email = email[len("mailto:"):]
+			}
+			current.attendees = append(current.attendees, oncallUser{
+				id:    email,
+				name:  prop.params["CN"],
+				email: email,
+			})
+		}
+	}
+
+	return events, nil
+}
+
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseICSProperty(line string) (icsProperty, bool) {
+	// The value starts after the first colon not enclosed in quotes.
+	inQuotes := false
+	sep := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			sep = i
+			break
+		}
+	}
+	if sep == -1 {
+		return icsProperty{}, false
+	}
+
+	prop := icsProperty{
+		params: map[string]string{},
+		value:  line[sep+1:],
+	}
+	nameAndParams := strings.Split(line[:sep], ";")
+	prop.name = strings.ToUpper(nameAndParams[0])
+	for _, param := range nameAndParams[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return prop, true
+}
+
+func parseICSTime(prop icsProperty) (time.Time, error) {
+	if prop.params["VALUE"] == "DATE" || len(prop.value) == len("20060102") {
+		return time.ParseInLocation("20060102", prop.value, time.Local)
+	}
+
+	if strings.HasSuffix(prop.value, "Z") {
+		return time.Parse("20060102T150405Z", prop.value)
+	}
+
+	loc := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to load time zone %q: %s", tzid, err)
+		}
+	}
+
+	return time.ParseInLocation("20060102T150405", prop.value, loc)
+}
+
+// parseICSDuration parses durations like P1D, PT8H, or P1W.
+func parseICSDuration(s string) (time.Duration, error) {
+	orig := s
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	s = s[1:]
+
+	var (
+		d      time.Duration
+		num    int
+		inTime bool
+		seen   bool
+	)
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+			seen = true
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+
+		if !seen {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(num) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		num = 0
+		seen = false
+	}
+
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+var icsTextReplacer = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescapeICSText(s string) string {
+	return icsTextReplacer.Replace(s)
+}
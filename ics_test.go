@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testICSFeed = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:On call: Jane Doe\r\n" +
+	"DTSTART:20240101T090000Z\r\n" +
+	"DTEND:20240108T090000Z\r\n" +
+	"ATTENDEE;CN=Jane Doe;ROLE=REQ-PARTICIPANT:mailto:jane@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:On call: John\r\n" +
+	"  Doe\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20240108T100000\r\n" +
+	"DURATION:P1W\r\n" +
+	"ATTENDEE;CN=\"Doe, John\":mailto:john@example.com\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICSCalendarOnCallUsersAt(t *testing.T) {
+	events, err := parseICSEvents(strings.NewReader(testICSFeed))
+	if err != nil {
+		t.Fatalf("failed to parse feed: %s", err)
+	}
+
+	tests := []struct {
+		name      string
+		summaryRE *regexp.Regexp
+		inNow     time.Time
+		wantUsers []oncallUser
+	}{
+		{
+			name:  "attendee of first event",
+			inNow: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			wantUsers: []oncallUser{
+				{id: "jane@example.com", name: "Jane Doe", email: "jane@example.com", until: time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:  "attendee of event with time zone and duration",
+			inNow: time.Date(2024, 1, 15, 8, 59, 0, 0, time.UTC),
+			wantUsers: []oncallUser{
+				{id: "john@example.com", name: "Doe, John", email: "john@example.com", until: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:      "name from summary",
+			summaryRE: regexp.MustCompile(`^On call: (.+)$`),
+			inNow:     time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			wantUsers: []oncallUser{
+				{id: "John Doe", name: "John Doe", until: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:  "no event at given time",
+			inNow: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := &icsCalendar{summaryRE: tt.summaryRE}
+			gotUsers := cal.onCallUsersAt(events, tt.inNow)
+			if diff := cmp.Diff(tt.wantUsers, gotUsers, cmp.AllowUnexported(oncallUser{})); diff != "" {
+				t.Errorf("on-call users mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestICSCalendarGetScheduleIDs(t *testing.T) {
+	var schedules oncallSchedules
+	for _, cfg := range []struct {
+		name      string
+		summaryRE *regexp.Regexp
+	}{
+		{name: "Attendees"},
+		{name: "Primary", summaryRE: regexp.MustCompile(`^Primary: (.+)$`)},
+		{name: "Secondary", summaryRE: regexp.MustCompile(`^Secondary: (.+)$`)},
+	} {
+		cal := &icsCalendar{url: "https://calendar.example.com/oncall.ics", summaryRE: cfg.summaryRE}
+		schedule, err := cal.getSchedule(context.Background(), "", cfg.name)
+		if err != nil {
+			t.Fatalf("failed to get schedule %q: %s", cfg.name, err)
+		}
+		schedules.ensureSchedule(*schedule)
+	}
+
+	if len(schedules) != 3 {
+		t.Errorf("got %d schedules for the same feed with different summary patterns, want 3", len(schedules))
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
@@ -14,7 +16,26 @@ import (
 
 var (
 	pdToken                  string
+	ogToken                  string
+	ogURL                    string
 	slToken                  string
+	slUserToken              string
+	slAppToken               string
+	slSigningSecret          string
+	eventsAddr               string
+	teamsTenantID            string
+	teamsClientID            string
+	teamsClientSecret        string
+	teamsGraphURL            string
+	teamsLoginURL            string
+	mattermostURL            string
+	mattermostToken          string
+	discordToken             string
+	discordURL               string
+	smtpAddr                 string
+	smtpFrom                 string
+	smtpUsername             string
+	smtpPassword             string
 	notAlphaNumRE            = regexp.MustCompile(`[^[:alnum:]]`)
 	daemonMinUpdateFrequency = 1 * time.Minute
 	daemonMaxExecutionTime   time.Duration
@@ -29,10 +50,10 @@ func main() {
 	)
 	app := &cli.App{
 		Name:  "pdsync",
-		Usage: "sync PagerDuty on-call schedules to Slack",
-		UsageText: `Poll a list of PagerDuty schedules for on-call personnel and update a Slack channel's topic using a predefined template
+		Usage: "sync PagerDuty and Opsgenie on-call schedules to Slack",
+		UsageText: `Poll a list of PagerDuty schedules and escalation policies for on-call personnel and update a Slack channel's topic using a predefined template
 
-Schedules can be given as names or IDs. Similarly, the channel to update the topic for can be specified by name or ID.
+Schedules and escalation policies can be given as names or IDs. Similarly, the channel to update the topic for can be specified by name or ID.
 
 Optionally, a set of Slack user groups can be kept in sync. This can be used to manage on-call handles.
 
@@ -47,17 +68,133 @@ By default, the program will terminate after a single run. Use the --daemon flag
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "pagerduty-token",
-				Usage:       "the PagerDuty token",
+				Usage:       "the PagerDuty token (required for syncs using the PagerDuty provider)",
 				Destination: &pdToken,
 				EnvVars:     []string{"PAGERDUTY_TOKEN"},
-				Required:    true,
+			},
+			&cli.StringFlag{
+				Name:        "opsgenie-token",
+				Usage:       "the Opsgenie API key (required for syncs using the Opsgenie provider)",
+				Destination: &ogToken,
+				EnvVars:     []string{"OPSGENIE_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:        "opsgenie-url",
+				Value:       defaultOpsgenieURL,
+				Usage:       "the Opsgenie API base URL (e.g., https://api.eu.opsgenie.com for the EU instance)",
+				Destination: &ogURL,
+				EnvVars:     []string{"OPSGENIE_URL"},
 			},
 			&cli.StringFlag{
 				Name:        "slack-token",
-				Usage:       "the Slack token",
+				Usage:       "the Slack token of the default Slack connection (required for syncs not referencing a named Slack connection)",
 				Destination: &slToken,
 				EnvVars:     []string{"SLACK_TOKEN"},
-				Required:    true,
+			},
+			&cli.StringFlag{
+				Name:        "slack-user-token",
+				Usage:       "the Slack user token used to set user statuses (needs the users.profile:write scope and admin permissions)",
+				Destination: &slUserToken,
+				EnvVars:     []string{"SLACK_USER_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:        "slack-app-token",
+				Usage:       "the Slack app-level token used to answer the /oncall command via Socket Mode in daemon mode (needs the connections:write scope)",
+				Destination: &slAppToken,
+				EnvVars:     []string{"SLACK_APP_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:        "slack-signing-secret",
+				Usage:       "the signing secret of the Slack app used to verify Events API requests",
+				Destination: &slSigningSecret,
+				EnvVars:     []string{"SLACK_SIGNING_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:        "events-addr",
+				Usage:       "the address to serve the Slack Events API on in daemon mode to answer app mentions (e.g., :8080)",
+				Destination: &eventsAddr,
+				EnvVars:     []string{"EVENTS_ADDR"},
+			},
+			&cli.StringFlag{
+				Name:        "teams-tenant-id",
+				Usage:       "the Microsoft Entra tenant ID of the app used for syncs targeting Microsoft Teams",
+				Destination: &teamsTenantID,
+				EnvVars:     []string{"TEAMS_TENANT_ID"},
+			},
+			&cli.StringFlag{
+				Name:        "teams-client-id",
+				Usage:       "the client ID of the app used for syncs targeting Microsoft Teams",
+				Destination: &teamsClientID,
+				EnvVars:     []string{"TEAMS_CLIENT_ID"},
+			},
+			&cli.StringFlag{
+				Name:        "teams-client-secret",
+				Usage:       "the client secret of the app used for syncs targeting Microsoft Teams",
+				Destination: &teamsClientSecret,
+				EnvVars:     []string{"TEAMS_CLIENT_SECRET"},
+			},
+			&cli.StringFlag{
+				Name:        "teams-graph-url",
+				Value:       defaultTeamsGraphURL,
+				Usage:       "the Microsoft Graph API base URL",
+				Destination: &teamsGraphURL,
+				EnvVars:     []string{"TEAMS_GRAPH_URL"},
+			},
+			&cli.StringFlag{
+				Name:        "teams-login-url",
+				Value:       defaultTeamsLoginURL,
+				Usage:       "the Microsoft identity platform base URL to obtain access tokens from",
+				Destination: &teamsLoginURL,
+				EnvVars:     []string{"TEAMS_LOGIN_URL"},
+			},
+			&cli.StringFlag{
+				Name:        "mattermost-url",
+				Usage:       "the base URL of the Mattermost server used for syncs targeting Mattermost",
+				Destination: &mattermostURL,
+				EnvVars:     []string{"MATTERMOST_URL"},
+			},
+			&cli.StringFlag{
+				Name:        "mattermost-token",
+				Usage:       "the Mattermost access token used for syncs targeting Mattermost",
+				Destination: &mattermostToken,
+				EnvVars:     []string{"MATTERMOST_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:        "discord-token",
+				Usage:       "the Discord bot token used for syncs targeting Discord",
+				Destination: &discordToken,
+				EnvVars:     []string{"DISCORD_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name:        "discord-url",
+				Usage:       "the Discord API base URL",
+				Value:       defaultDiscordURL,
+				Destination: &discordURL,
+				EnvVars:     []string{"DISCORD_URL"},
+			},
+			&cli.StringFlag{
+				Name:        "smtp-addr",
+				Usage:       "the SMTP server address (host:port) used for syncs sending emails",
+				Destination: &smtpAddr,
+				EnvVars:     []string{"SMTP_ADDR"},
+			},
+			&cli.StringFlag{
+				Name:        "smtp-from",
+				Usage:       "the sender address of emails",
+				Destination: &smtpFrom,
+				EnvVars:     []string{"SMTP_FROM"},
+			},
+			&cli.StringFlag{
+				Name:        "smtp-username",
+				Usage:       "the SMTP username (authentication is skipped if empty)",
+				Destination: &smtpUsername,
+				EnvVars:     []string{"SMTP_USERNAME"},
+			},
+			&cli.StringFlag{
+				Name:        "smtp-password",
+				Usage:       "the SMTP password",
+				Destination: &smtpPassword,
+				EnvVars:     []string{"SMTP_PASSWORD"},
 			},
 			&cli.StringFlag{
 				Name:        "config",
@@ -68,6 +205,10 @@ By default, the program will terminate after a single run. Use the --daemon flag
 				Name:  "schedule",
 				Usage: "name of a PageDuty schedule to sync periodically (can be repeated to define several schedules); syntax: id|name=<schedule reference>[;userGroup=id|name|handle=<user group reference>..]",
 			},
+			&cli.StringSliceFlag{
+				Name:  "escalation-policy",
+				Usage: "name of a PagerDuty escalation policy to sync periodically (can be repeated to define several escalation policies); syntax: id|name=<escalation policy reference>[;level=<escalation level>..][;userGroup=id|name|handle=<user group reference>..]",
+			},
 			&cli.StringFlag{
 				Name:        "channel-name",
 				Usage:       "the name of the channel to post topic updates to",
@@ -124,9 +265,16 @@ By default, the program will terminate after a single run. Use the --daemon flag
 				Usage:       "fail on the first schedule that cannot be synced, and otherwise handle failures gracefully (defaults to false when running in daemon mode, otherwise true)",
 				Destination: &p.failFast,
 			},
+			&cli.StringFlag{
+				Name:        "state-file",
+				Usage:       "a `FILE` to persist state in across restarts, such as the previous on-call users (default: in-memory only)",
+				Destination: &p.stateFile,
+				EnvVars:     []string{"STATE_FILE"},
+			},
 		},
 		Action: func(c *cli.Context) error {
 			p.schedules = c.StringSlice("schedule")
+			p.escalationPolicies = c.StringSlice("escalation-policy")
 			if c.IsSet("pretend-users") {
 				p.pretendUsers = &pretendUsers
 			}
@@ -153,43 +301,130 @@ func realMain(p params) error {
 		return err
 	}
 
+	if eventsAddr != "" && slSigningSecret == "" {
+		return errors.New("Slack signing secret must be given when serving Slack events")
+	}
+
 	if p.daemonUpdateFrequency < daemonMinUpdateFrequency {
 		p.daemonUpdateFrequency = daemonMinUpdateFrequency
 	}
 
 	sp := syncerParams{
-		pdClient: newPagerDutyClient(pdToken),
-		slClient: newSlackMetaClient(slToken, includePrivateChannels),
+		slackConnections: map[string]*slackConnection{},
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
-	defer stop()
+	usedProviders := map[string]bool{}
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.usesProvider() {
+			usedProviders[cfgSlSync.Provider] = true
+		}
+	}
+	if usedProviders[providerPagerDuty] {
+		if pdToken == "" {
+			return errors.New("PagerDuty token must be given when syncing PagerDuty schedules")
+		}
+		sp.pdClient = newPagerDutyClient(pdToken)
+	}
+	if usedProviders[providerOpsgenie] {
+		if ogToken == "" {
+			return errors.New("Opsgenie token must be given when syncing Opsgenie schedules")
+		}
+		sp.ogClient = newOpsgenieClient(ogToken, ogURL)
+	}
 
-	fmt.Println("Getting Slack users")
-	sp.slackUsers, err = sp.slClient.getSlackUsers(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get Slack users: %s", err)
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.Teams != nil {
+			if teamsTenantID == "" || teamsClientID == "" || teamsClientSecret == "" {
+				return errors.New("Microsoft Teams tenant ID, client ID, and client secret must be given when syncing to Microsoft Teams")
+			}
+			sp.teamsClient = newTeamsClient(teamsTenantID, teamsClientID, teamsClientSecret, teamsGraphURL, teamsLoginURL)
+			break
+		}
 	}
-	fmt.Printf("Found %d Slack user(s)\n", len(sp.slackUsers))
 
-	fmt.Println("Getting Slack user groups")
-	sp.slackUserGroups, err = sp.slClient.getUserGroups(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get Slack user groups: %s", err)
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.Mattermost != nil {
+			if mattermostURL == "" || mattermostToken == "" {
+				return errors.New("Mattermost URL and token must be given when syncing to Mattermost")
+			}
+			sp.mmClient = newMattermostClient(mattermostURL, mattermostToken)
+			break
+		}
+	}
+
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.Discord != nil {
+			if discordToken == "" {
+				return errors.New("Discord token must be given when syncing to Discord")
+			}
+			sp.discordClient = newDiscordClient(discordToken, discordURL)
+			break
+		}
+	}
+
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.Webhook != nil {
+			sp.webhookClient = newWebhookClient()
+			break
+		}
+	}
+
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		if cfgSlSync.Email != nil {
+			if smtpAddr == "" || smtpFrom == "" {
+				return errors.New("SMTP address and sender must be given when sending emails")
+			}
+			sp.emailClient = newEmailClient(smtpAddr, smtpFrom, smtpUsername, smtpPassword)
+			break
+		}
+	}
+
+	for _, cfgSlSync := range cfg.SlackSyncs {
+		conn, ok := sp.slackConnections[cfgSlSync.SlackConnection]
+		if !ok {
+			conn, err = newSlackConnection(cfg.SlackConnections, cfgSlSync.SlackConnection)
+			if err != nil {
+				return err
+			}
+			sp.slackConnections[cfgSlSync.SlackConnection] = conn
+		}
+		if cfgSlSync.usesSlackStatus() && conn.statusClient == nil {
+			return fmt.Errorf("Slack user token of Slack connection %s must be given when setting user statuses", conn)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	for _, conn := range sp.slackConnections {
+		if err := conn.load(ctx); err != nil {
+			return fmt.Errorf("failed to load Slack connection %s: %s", conn, err)
+		}
 	}
-	fmt.Printf("Found %d Slack user group(s)\n", len(sp.slackUserGroups))
 
 	slSyncs, err := sp.createSlackSyncs(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create Slack syncs: %s", err)
 	}
 
+	if p.stateFile != "" {
+		sp.stateStore = newStateStore(p.stateFile)
+	}
 	syncer := newSyncer(sp)
+	if err := syncer.restoreState(); err != nil {
+		return fmt.Errorf("failed to restore state: %s", err)
+	}
 
 	runFunc := func() error {
 		return syncer.Run(ctx, slSyncs, p.failFast)
 	}
 	if !p.daemon {
+		if slAppToken != "" {
+			fmt.Println("Ignoring Slack app token outside of daemon mode")
+		}
+		if eventsAddr != "" {
+			fmt.Println("Ignoring events address outside of daemon mode")
+		}
 		return runFunc()
 	}
 
@@ -201,6 +436,38 @@ func realMain(p params) error {
 		defer cancel()
 	}
 
+	// The /oncall command and app mentions are served by the app of the
+	// default Slack connection and cover the syncs using it only.
+	defaultConn := sp.slackConnections[defaultSlackConnection]
+	if defaultConn == nil && (slAppToken != "" || eventsAddr != "") {
+		return errors.New("answering the /oncall command and app mentions requires a sync using the default Slack connection")
+	}
+	var cmd onCallCommand
+	if defaultConn != nil {
+		cmd.slackUsers = defaultConn.users
+		for _, slSync := range slSyncs {
+			if slSync.slack == defaultConn {
+				cmd.slackSyncs = append(cmd.slackSyncs, slSync)
+			}
+		}
+	}
+	if slAppToken != "" {
+		go func() {
+			if err := runSocketMode(daemonCtx, slToken, slAppToken, cmd); err != nil && daemonCtx.Err() == nil {
+				fmt.Printf("Socket Mode connection failed: %s\n", err)
+			}
+		}()
+	}
+	if eventsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/slack/events", newEventsHandler(slSigningSecret, defaultConn.client, cmd))
+		go func() {
+			if err := runEventsServer(daemonCtx, eventsAddr, mux); err != nil {
+				fmt.Printf("Events server failed: %s\n", err)
+			}
+		}()
+	}
+
 	fmt.Println("Starting daemon")
 	startDaemon(daemonCtx, p.daemonUpdateFrequency, runFunc)
 
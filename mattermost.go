@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// mattermostUsernames holds the Mattermost usernames of all users on call for
+// a schedule. When rendered directly in a template, the usernames are joined by
+// commas.
+type mattermostUsernames []string
+
+func (names mattermostUsernames) String() string {
+	return strings.Join(names, ",")
+}
+
+// mattermostTemplateFuncs mirrors templateFuncs for Mattermost.
+var mattermostTemplateFuncs = template.FuncMap{
+	"mentions": func(names mattermostUsernames) string {
+		userMentions := make([]string, 0, len(names))
+		for _, name := range names {
+			userMentions = append(userMentions, "@"+name)
+		}
+		return strings.Join(userMentions, ", ")
+	},
+}
+
+type runMattermostSync struct {
+	channelID string
+	tmpl      *template.Template
+	groups    []runMattermostGroup
+}
+
+type runMattermostGroup struct {
+	id   string
+	name string
+	// schedules are the names of the schedules whose on-call users should be
+	// group members. Empty means all schedules of the sync.
+	schedules []string
+}
+
+func (sp syncerParams) createMattermostSync(ctx context.Context, slSyncName string, cfgSlSync ConfigSlackSync, schedules oncallSchedules) (*runMattermostSync, error) {
+	cfgMattermost := cfgSlSync.Mattermost
+	if sp.mmClient == nil {
+		return nil, errors.New("Mattermost client is not configured")
+	}
+
+	mmSync := &runMattermostSync{}
+
+	cfgChannel := cfgMattermost.Channel
+	if cfgChannel.ID != "" || cfgChannel.Name != "" {
+		tmplString := cfgMattermost.Template
+		if tmplString == "" {
+			tmplString = cfgSlSync.Template
+		}
+		var err error
+		mmSync.tmpl, err = template.New("header").Funcs(mattermostTemplateFuncs).Parse(tmplString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %q: %s", tmplString, err)
+		}
+
+		channel, err := sp.mmClient.getChannel(ctx, cfgChannel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get channel %s: %s", cfgChannel, err)
+		}
+		if channel == nil {
+			return nil, fmt.Errorf("failed to find configured Mattermost channel %s", cfgChannel)
+		}
+		mmSync.channelID = channel.ID
+		fmt.Printf("Slack sync %s: found Mattermost channel %q (ID %s)\n", slSyncName, channel.Name, channel.ID)
+	}
+
+	if len(cfgMattermost.Groups) == 0 {
+		return mmSync, nil
+	}
+
+	fmt.Printf("Slack sync %s: Getting Mattermost custom groups\n", slSyncName)
+	groups, err := sp.mmClient.getCustomGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom groups: %s", err)
+	}
+
+	for _, cfgGroup := range cfgMattermost.Groups {
+		var found *mattermostGroup
+		for _, group := range groups {
+			if (cfgGroup.ID != "" && group.ID == cfgGroup.ID) || (cfgGroup.Name != "" && group.Name == cfgGroup.Name) {
+				found = &group
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("custom group %s not found", cfgGroup)
+		}
+
+		for _, schedName := range cfgGroup.Schedules {
+			if !schedules.hasName(schedName) {
+				return nil, fmt.Errorf("custom group %s references unknown schedule %q", cfgGroup, schedName)
+			}
+		}
+
+		fmt.Printf("Slack sync %s: found Mattermost custom group %q (ID %s)\n", slSyncName, found.Name, found.ID)
+		mmSync.groups = append(mmSync.groups, runMattermostGroup{
+			id:        found.ID,
+			name:      found.Name,
+			schedules: cfgGroup.Schedules,
+		})
+	}
+
+	return mmSync, nil
+}
+
+func (s *syncer) runMattermostSync(ctx context.Context, mmSync runMattermostSync, onCalls []scheduleOnCall, dryRun bool) error {
+	usersByEmail := map[string]mattermostUser{}
+	for _, onCall := range onCalls {
+		for _, user := range onCall.users {
+			if user.email == "" {
+				return fmt.Errorf("cannot find Mattermost user for on-call user without email %s", user)
+			}
+			mmUser, err := s.mmClient.getUserByEmail(ctx, user.email)
+			if err != nil {
+				return fmt.Errorf("failed to get Mattermost user for on-call user %s: %s", user, err)
+			}
+			if mmUser == nil {
+				return fmt.Errorf("failed to find Mattermost user for on-call user %s", user)
+			}
+			usersByEmail[user.email] = *mmUser
+		}
+	}
+
+	for _, group := range mmSync.groups {
+		var userIDs []string
+		for _, onCall := range onCalls {
+			if !includesSchedule(group.schedules, onCall.schedule.name) {
+				continue
+			}
+			for _, user := range onCall.users {
+				userIDs = appendUnique(userIDs, usersByEmail[user.email].ID)
+			}
+		}
+
+		if err := s.mmClient.updateGroupMembers(ctx, group, userIDs, dryRun); err != nil {
+			return fmt.Errorf("failed to update custom group members: %s", err)
+		}
+	}
+
+	if mmSync.tmpl == nil {
+		fmt.Println("Skipping Mattermost channel header update")
+		return nil
+	}
+
+	usernamesByScheduleName := map[string]mattermostUsernames{}
+	for _, onCall := range onCalls {
+		usernames := mattermostUsernames{}
+		for _, user := range onCall.users {
+			usernames = append(usernames, usersByEmail[user.email].Username)
+		}
+		usernamesByScheduleName[templateVarName(onCall.schedule.name)] = usernames
+	}
+
+	var buf bytes.Buffer
+	fmt.Printf("Executing Mattermost template with usernames by schedule name: %s\n", usernamesByScheduleName)
+	if err := mmSync.tmpl.Execute(&buf, usernamesByScheduleName); err != nil {
+		return fmt.Errorf("failed to render template: %s", err)
+	}
+
+	if err := s.mmClient.updateChannelHeader(ctx, mmSync.channelID, buf.String(), dryRun); err != nil {
+		return fmt.Errorf("failed to update channel header: %s", err)
+	}
+
+	return nil
+}
+
+type mattermostUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type mattermostChannel struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Header string `json:"header"`
+}
+
+type mattermostGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type mattermostClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+
+	usersMu sync.Mutex
+	// usersByEmail caches users by email address.
+	usersByEmail map[string]mattermostUser
+}
+
+func newMattermostClient(baseURL, token string) *mattermostClient {
+	return &mattermostClient{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		token:        token,
+		usersByEmail: map[string]mattermostUser{},
+	}
+}
+
+func (cl *mattermostClient) getUserByEmail(ctx context.Context, email string) (*mattermostUser, error) {
+	cl.usersMu.Lock()
+	defer cl.usersMu.Unlock()
+
+	if user, ok := cl.usersByEmail[email]; ok {
+		return &user, nil
+	}
+
+	var user mattermostUser
+	if err := cl.do(ctx, http.MethodGet, "/users/email/"+url.PathEscape(email), nil, &user); err != nil {
+		if isHTTPStatus(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cl.usersByEmail[email] = user
+
+	return &user, nil
+}
+
+func (cl *mattermostClient) getChannel(ctx context.Context, cfgChannel ConfigMattermostChannel) (*mattermostChannel, error) {
+	path := "/channels/" + url.PathEscape(cfgChannel.ID)
+	if cfgChannel.ID == "" {
+		path = "/teams/name/" + url.PathEscape(cfgChannel.Team) + "/channels/name/" + url.PathEscape(cfgChannel.Name)
+	}
+
+	var channel mattermostChannel
+	if err := cl.do(ctx, http.MethodGet, path, nil, &channel); err != nil {
+		if isHTTPStatus(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &channel, nil
+}
+
+func (cl *mattermostClient) getCustomGroups(ctx context.Context) ([]mattermostGroup, error) {
+	const perPage = 200
+
+	var groups []mattermostGroup
+	for page := 0; ; page++ {
+		query := url.Values{
+			"source":   {"custom"},
+			"page":     {fmt.Sprint(page)},
+			"per_page": {fmt.Sprint(perPage)},
+		}
+		var pageGroups []mattermostGroup
+		if err := cl.do(ctx, http.MethodGet, "/groups?"+query.Encode(), nil, &pageGroups); err != nil {
+			return nil, err
+		}
+		groups = append(groups, pageGroups...)
+
+		if len(pageGroups) < perPage {
+			break
+		}
+	}
+
+	return groups, nil
+}
+
+func (cl *mattermostClient) updateGroupMembers(ctx context.Context, group runMattermostGroup, userIDs []string, dryRun bool) error {
+	membersPath := "/groups/" + url.PathEscape(group.id) + "/members"
+	var resp struct {
+		Members []mattermostUser `json:"members"`
+	}
+	if err := cl.do(ctx, http.MethodGet, membersPath+"?per_page=200", nil, &resp); err != nil {
+		return fmt.Errorf("failed to get members of custom group %q: %s", group.name, err)
+	}
+
+	var currentIDs []string
+	for _, member := range resp.Members {
+		currentIDs = append(currentIDs, member.ID)
+	}
+	var toAdd, toRemove []string
+	for _, userID := range userIDs {
+		if !containsString(currentIDs, userID) {
+			toAdd = append(toAdd, userID)
+		}
+	}
+	for _, currentID := range currentIDs {
+		if !containsString(userIDs, currentID) {
+			toRemove = append(toRemove, currentID)
+		}
+	}
+
+	if len(toAdd) == 0 && len(toRemove) == 0 {
+		fmt.Printf("Custom group %q already has the right members\n", group.name)
+		return nil
+	}
+	concatMembers := strings.Join(userIDs, ",")
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not updating custom group %s with member(s): %s\n", group.name, concatMembers)
+		return nil
+	}
+
+	if len(toAdd) > 0 {
+		if err := cl.do(ctx, http.MethodPost, membersPath, map[string][]string{"user_ids": toAdd}, nil); err != nil {
+			return fmt.Errorf("failed to add members to custom group %q: %s", group.name, err)
+		}
+	}
+	if len(toRemove) > 0 {
+		if err := cl.do(ctx, http.MethodDelete, membersPath, map[string][]string{"user_ids": toRemove}, nil); err != nil {
+			return fmt.Errorf("failed to remove members from custom group %q: %s", group.name, err)
+		}
+	}
+	fmt.Printf("Updated custom group %s with member(s): %s\n", group.name, concatMembers)
+
+	return nil
+}
+
+func (cl *mattermostClient) updateChannelHeader(ctx context.Context, channelID, header string, dryRun bool) error {
+	var channel mattermostChannel
+	if err := cl.do(ctx, http.MethodGet, "/channels/"+url.PathEscape(channelID), nil, &channel); err != nil {
+		return err
+	}
+
+	if channel.Header == header {
+		fmt.Println("Mattermost channel header already set correctly")
+		return nil
+	}
+
+	fmt.Printf("Updating Mattermost channel header from\n[BEGIN-OF-OLD]\n%s\n[END-OF-OLD]\nto:\n[BEGIN-OF-NEW]\n%s\n[END-OF-NEW]\n", channel.Header, header)
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating Mattermost channel header")
+		return nil
+	}
+	if err := cl.do(ctx, http.MethodPut, "/channels/"+url.PathEscape(channelID)+"/patch", map[string]string{"header": header}, nil); err != nil {
+		return err
+	}
+	fmt.Println("Mattermost channel header updated")
+
+	return nil
+}
+
+func (cl *mattermostClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+cl.token)
+	return retryOnHTTPRateLimit("Mattermost", func() error {
+		return doJSONRequest(ctx, cl.httpClient, method, cl.baseURL+"/api/v4"+path, header, in, out)
+	})
+}
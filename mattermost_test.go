@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeMattermost is a minimal stand-in for the Mattermost API.
+type fakeMattermost struct {
+	header       string
+	groupMembers []string
+}
+
+func (fm *fakeMattermost) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/api/v4/users/email/jane@example.com":
+			fmt.Fprint(w, `{"id": "user-jane", "username": "jane"}`)
+		case r.URL.Path == "/api/v4/groups/group-1/members" && r.Method == http.MethodGet:
+			var members []mattermostUser
+			for _, id := range fm.groupMembers {
+				members = append(members, mattermostUser{ID: id})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"members": members})
+		case r.URL.Path == "/api/v4/groups/group-1/members" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+			var req struct {
+				UserIDs []string `json:"user_ids"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			var members []string
+			for _, id := range fm.groupMembers {
+				if !containsString(req.UserIDs, id) {
+					members = append(members, id)
+				}
+			}
+			if r.Method == http.MethodPost {
+				members = append(members, req.UserIDs...)
+			}
+			sort.Strings(members)
+			fm.groupMembers = members
+			fmt.Fprint(w, `[]`)
+		case r.URL.Path == "/api/v4/channels/channel" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(mattermostChannel{ID: "channel", Header: fm.header})
+		case r.URL.Path == "/api/v4/channels/channel/patch" && r.Method == http.MethodPut:
+			var req struct {
+				Header string `json:"header"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			fm.header = req.Header
+			fmt.Fprint(w, `{}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRunMattermostSync(t *testing.T) {
+	fm := &fakeMattermost{
+		header:       "old header",
+		groupMembers: []string{"user-john"},
+	}
+	srv := httptest.NewServer(fm.handler(t))
+	defer srv.Close()
+
+	s := newSyncer(syncerParams{
+		mmClient: newMattermostClient(srv.URL+"/", "token"),
+	})
+	mmSync := runMattermostSync{
+		channelID: "channel",
+		tmpl:      template.Must(template.New("header").Funcs(mattermostTemplateFuncs).Parse("on-call: {{mentions .Primary}}")),
+		groups: []runMattermostGroup{
+			{id: "group-1", name: "oncall"},
+		},
+	}
+	onCalls := []scheduleOnCall{
+		{
+			schedule: oncallSchedule{id: "sched-1", name: "Primary"},
+			users:    []oncallUser{{id: "PD1", name: "Jane Doe", email: "jane@example.com"}},
+		},
+	}
+
+	if err := s.runMattermostSync(context.Background(), mmSync, onCalls, true); err != nil {
+		t.Fatalf("failed to run dry-run Mattermost sync: %s", err)
+	}
+	if fm.header != "old header" {
+		t.Errorf("got header %q in dry-run mode, want it unchanged", fm.header)
+	}
+
+	if err := s.runMattermostSync(context.Background(), mmSync, onCalls, false); err != nil {
+		t.Fatalf("failed to run Mattermost sync: %s", err)
+	}
+	if want := "on-call: @jane"; fm.header != want {
+		t.Errorf("got header %q, want %q", fm.header, want)
+	}
+	if diff := cmp.Diff([]string{"user-jane"}, fm.groupMembers); diff != "" {
+		t.Errorf("group members mismatch (-want +got):\n%s", diff)
+	}
+}
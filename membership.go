@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type runChannelMembership struct {
+	slackChannelIDs []string
+	removePrevious  bool
+}
+
+func (sp syncerParams) createChannelMembership(slSyncName string, cfgMembership ConfigChannelMembership, slChannels channelList) (*runChannelMembership, error) {
+	membership := &runChannelMembership{
+		removePrevious: cfgMembership.RemovePrevious,
+	}
+	for _, cfgChannel := range cfgMembership.Channels {
+		slChannel := slChannels.find(cfgChannel.ID, cfgChannel.Name)
+		if slChannel == nil {
+			return nil, fmt.Errorf("failed to find configured Slack channel %s", cfgChannel)
+		}
+		fmt.Printf("Slack sync %s: found Slack membership channel %q (ID %s)\n", slSyncName, slChannel.Name, slChannel.ID)
+		membership.slackChannelIDs = appendUnique(membership.slackChannelIDs, slChannel.ID)
+	}
+
+	return membership, nil
+}
+
+// runChannelMembership invites the on-call users of all schedules into the
+// configured channels. If configured, users that pdsync invited are removed
+// again once they are no longer on call, unless another sync invited them as
+// well; members that pdsync did not invite are never removed.
+func (s *syncer) runChannelMembership(ctx context.Context, membership runChannelMembership, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	var wantMembers []string
+	for _, onCall := range onCalls {
+		for _, onCallUser := range onCall.users {
+			slUser := slackSync.slack.users.findByOncallUser(onCallUser)
+			if slUser == nil {
+				return fmt.Errorf("failed to find Slack user for on-call user %s", onCallUser)
+			}
+			wantMembers = appendUnique(wantMembers, slUser.id)
+		}
+	}
+
+	invitedByChannel := s.channelMembers[slackSync.name]
+	if invitedByChannel == nil {
+		invitedByChannel = map[string][]string{}
+		s.channelMembers[slackSync.name] = invitedByChannel
+	}
+
+	for _, channelID := range membership.slackChannelIDs {
+		curMembers, err := slackSync.slack.client.getChannelMembers(ctx, channelID)
+		if err != nil {
+			return fmt.Errorf("failed to get members of channel %s: %s", channelID, err)
+		}
+
+		var keepInvited, remove []string
+		for _, userID := range invitedByChannel[channelID] {
+			switch {
+			case containsString(wantMembers, userID):
+				keepInvited = append(keepInvited, userID)
+			case membership.removePrevious && containsString(curMembers, userID):
+				if s.isChannelMemberInvitedByOtherSync(slackSync.name, channelID, userID) {
+					fmt.Printf("Not removing user %s from channel %s since another sync invited them\n", userID, channelID)
+					continue
+				}
+				remove = append(remove, userID)
+			case !membership.removePrevious:
+				keepInvited = append(keepInvited, userID)
+			}
+		}
+		var invite []string
+		for _, userID := range wantMembers {
+			if !containsString(curMembers, userID) {
+				invite = append(invite, userID)
+				continue
+			}
+			if !containsString(keepInvited, userID) && s.isChannelMemberInvitedByOtherSync(slackSync.name, channelID, userID) {
+				// Adopt the member so that it gets removed by whichever sync
+				// stops wanting it last.
+				fmt.Printf("User %s in channel %s was already invited by another sync\n", userID, channelID)
+				if !slackSync.dryRun {
+					keepInvited = append(keepInvited, userID)
+				}
+			}
+		}
+
+		if len(invite) == 0 && len(remove) == 0 {
+			fmt.Printf("Channel %s already has the right members\n", channelID)
+			invitedByChannel[channelID] = keepInvited
+			continue
+		}
+
+		if len(invite) > 0 {
+			if err := slackSync.slack.client.inviteToChannel(ctx, channelID, invite, slackSync.dryRun); err != nil {
+				return fmt.Errorf("failed to invite user(s) to channel %s: %s", channelID, err)
+			}
+			if !slackSync.dryRun {
+				for _, userID := range invite {
+					keepInvited = appendUnique(keepInvited, userID)
+				}
+			}
+		}
+
+		for _, userID := range remove {
+			if err := slackSync.slack.client.removeFromChannel(ctx, channelID, userID, slackSync.dryRun); err != nil {
+				return fmt.Errorf("failed to remove user %s from channel %s: %s", userID, channelID, err)
+			}
+			if slackSync.dryRun {
+				keepInvited = append(keepInvited, userID)
+			}
+		}
+
+		invitedByChannel[channelID] = keepInvited
+	}
+
+	return nil
+}
+
+func (s *syncer) isChannelMemberInvitedByOtherSync(syncName, channelID, userID string) bool {
+	for otherSyncName, invitedByChannel := range s.channelMembers {
+		if otherSyncName == syncName {
+			continue
+		}
+		if containsString(invitedByChannel[channelID], userID) {
+			return true
+		}
+	}
+	return false
+}
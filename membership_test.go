@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+func TestRunChannelMembership(t *testing.T) {
+	members := map[string][]string{
+		"C1": {"U3"},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		channelID := r.Form.Get("channel")
+
+		switch r.URL.Path {
+		case "/conversations.members":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "members": members[channelID]})
+		case "/conversations.invite":
+			members[channelID] = append(members[channelID], strings.Split(r.PostForm.Get("users"), ",")...)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": map[string]string{"id": channelID}})
+		case "/conversations.kick":
+			var kept []string
+			for _, member := range members[channelID] {
+				if member != r.PostForm.Get("user") {
+					kept = append(kept, member)
+				}
+			}
+			members[channelID] = kept
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+			{id: "U3", email: "max@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	membership := runChannelMembership{
+		slackChannelIDs: []string{"C1"},
+		removePrevious:  true,
+	}
+	slackSync := runSlackSync{name: "team", slack: conn}
+	jane := oncallUser{email: "jane@example.com"}
+	john := oncallUser{email: "john@example.com"}
+	max := oncallUser{email: "max@example.com"}
+
+	steps := []struct {
+		name   string
+		users  []oncallUser
+		dryRun bool
+		// otherInvited holds the users that another sync invited.
+		otherInvited []string
+		wantMembers  []string
+		wantInvited  []string
+	}{
+		{
+			name:        "invite on-call user",
+			users:       []oncallUser{jane, max},
+			wantMembers: []string{"U3", "U1"},
+			wantInvited: []string{"U1"},
+		},
+		{
+			name:        "dry run does not change members",
+			users:       []oncallUser{john},
+			dryRun:      true,
+			wantMembers: []string{"U3", "U1"},
+			wantInvited: []string{"U1"},
+		},
+		{
+			name:        "remove invited user only",
+			users:       []oncallUser{john},
+			wantMembers: []string{"U3", "U2"},
+			wantInvited: []string{"U2"},
+		},
+		{
+			name:         "keep user invited by another sync",
+			users:        []oncallUser{jane},
+			otherInvited: []string{"U2"},
+			wantMembers:  []string{"U3", "U2", "U1"},
+			wantInvited:  []string{"U1"},
+		},
+		{
+			name:         "adopt user invited by another sync",
+			users:        []oncallUser{jane, john},
+			otherInvited: []string{"U2"},
+			wantMembers:  []string{"U3", "U2", "U1"},
+			wantInvited:  []string{"U1", "U2"},
+		},
+		{
+			name:        "remove adopted user",
+			users:       []oncallUser{jane},
+			wantMembers: []string{"U3", "U1"},
+			wantInvited: []string{"U1"},
+		},
+	}
+
+	for _, step := range steps {
+		slackSync.dryRun = step.dryRun
+		s.channelMembers["other"] = map[string][]string{"C1": step.otherInvited}
+		onCalls := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: step.users}}
+		if err := s.runChannelMembership(context.Background(), membership, slackSync, onCalls); err != nil {
+			t.Fatalf("step %q: failed to run channel membership: %s", step.name, err)
+		}
+		if diff := cmp.Diff(step.wantMembers, members["C1"]); diff != "" {
+			t.Errorf("step %q: members mismatch (-want +got):\n%s", step.name, diff)
+		}
+		if diff := cmp.Diff(step.wantInvited, s.channelMembers["team"]["C1"]); diff != "" {
+			t.Errorf("step %q: invited users mismatch (-want +got):\n%s", step.name, diff)
+		}
+	}
+}
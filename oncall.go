@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// oncallProvider is a source of on-call schedules and the users on call for
+// them.
+type oncallProvider interface {
+	// getSchedule returns the schedule identified by either ID or name, or nil
+	// if no such schedule exists.
+	getSchedule(ctx context.Context, id, name string) (*oncallSchedule, error)
+	// getOnCallUsers returns the users currently on call for the given
+	// schedule.
+	getOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]oncallUser, error)
+}
+
+// upcomingShiftProvider is implemented by on-call providers that can look
+// ahead in schedules.
+type upcomingShiftProvider interface {
+	// getUpcomingShifts returns the shifts of the given schedule starting
+	// between now and until in chronological order.
+	getUpcomingShifts(ctx context.Context, schedule oncallSchedule, until time.Time) ([]oncallShift, error)
+}
+
+// oncallShift is a period during which a user is on call.
+type oncallShift struct {
+	user  oncallUser
+	start time.Time
+	end   time.Time
+}
+
+const (
+	providerPagerDuty = "pagerduty"
+	providerOpsgenie  = "opsgenie"
+)
+
+type oncallSchedules []oncallSchedule
+
+func (schedules *oncallSchedules) ensureSchedule(schedule oncallSchedule) {
+	for _, sched := range *schedules {
+		if sched.id == schedule.id {
+			return
+		}
+	}
+	*schedules = append(*schedules, schedule)
+}
+
+func (schedules oncallSchedules) hasName(name string) bool {
+	for _, sched := range schedules {
+		if sched.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type oncallSchedule struct {
+	id   string
+	name string
+	// escalationLevels is only set if the schedule represents a PagerDuty
+	// escalation policy, in which case it lists the escalation levels to
+	// consider.
+	escalationLevels []uint
+	userGroups       UserGroups
+	// descriptions holds the descriptions of user groups, keyed by user
+	// group ID.
+	descriptions map[string]userGroupDescription
+	// withShiftEnds is set if the end of the on-call users' shifts is used,
+	// which requires extra requests for some providers.
+	withShiftEnds bool
+	// slackStatus is the Slack status to set for on-call users, if any.
+	slackStatus *slackStatus
+	// provider is the oncallProvider the schedule was obtained from.
+	provider oncallProvider
+}
+
+// userGroupDescription is the template for a user group description along
+// with the location that shift ends are rendered in.
+type userGroupDescription struct {
+	tmpl *template.Template
+	loc  *time.Location
+}
+
+func (sched oncallSchedule) isEscalationPolicy() bool {
+	return len(sched.escalationLevels) > 0
+}
+
+func (sched oncallSchedule) String() string {
+	if sched.isEscalationPolicy() {
+		return fmt.Sprintf("{EscalationPolicyID:%s Name:%q Levels:%v}", sched.id, sched.name, sched.escalationLevels)
+	}
+	return fmt.Sprintf("{ID:%s Name:%q}", sched.id, sched.name)
+}
+
+// oncallUser is a user on call as reported by an oncallProvider.
+type oncallUser struct {
+	id    string
+	name  string
+	email string
+	// until is the end of the user's current on-call shift, or zero if
+	// unknown.
+	until time.Time
+}
+
+func (ou oncallUser) String() string {
+	return fmt.Sprintf("ID: %s Name: %s Email: %s", ou.id, ou.name, ou.email)
+}
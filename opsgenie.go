@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultOpsgenieURL = "https://api.opsgenie.com"
+
+// opsgenieClient is the oncallProvider implementation for Opsgenie.
+type opsgenieClient struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+
+	usersMu sync.Mutex
+	// users caches Opsgenie users by username.
+	users map[string]opsgenieUser
+}
+
+type opsgenieUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+}
+
+type opsgenieSchedule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type opsgenieOnCalls struct {
+	OnCallRecipients []string `json:"onCallRecipients"`
+}
+
+func newOpsgenieClient(apiKey, baseURL string) *opsgenieClient {
+	if baseURL == "" {
+		baseURL = defaultOpsgenieURL
+	}
+
+	return &opsgenieClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		users:      map[string]opsgenieUser{},
+	}
+}
+
+func (cl *opsgenieClient) getSchedule(ctx context.Context, id, name string) (*oncallSchedule, error) {
+	identifier, identifierType := id, "id"
+	if identifier == "" {
+		identifier, identifierType = name, "name"
+	}
+	if identifier == "" {
+		return nil, errors.New("schedule ID or name is missing")
+	}
+
+	fmt.Printf("Looking up Opsgenie schedule by %s %s\n", identifierType, identifier)
+	var schedule opsgenieSchedule
+	err := cl.get(ctx, "/v2/schedules/"+url.PathEscape(identifier), url.Values{"identifierType": {identifierType}}, &schedule)
+	if err != nil {
+		if isHTTPStatus(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get schedule by %s: %s", identifierType, err)
+	}
+
+	return &oncallSchedule{
+		id:   schedule.ID,
+		name: schedule.Name,
+	}, nil
+}
+
+func (cl *opsgenieClient) getOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]oncallUser, error) {
+	fmt.Printf("Getting on-call users for Opsgenie schedule %s\n", schedule)
+	var onCalls opsgenieOnCalls
+	err := cl.get(ctx, "/v2/schedules/"+url.PathEscape(schedule.id)+"/on-calls", url.Values{
+		"scheduleIdentifierType": {"id"},
+		"flat":                   {"true"},
+	}, &onCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(onCalls.OnCallRecipients) == 0 {
+		fmt.Printf("Got no on-call users for schedule %s\n", schedule)
+	}
+	onCallUsers := make([]oncallUser, 0, len(onCalls.OnCallRecipients))
+	for _, username := range onCalls.OnCallRecipients {
+		user, err := cl.getUser(ctx, username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %s", username, err)
+		}
+		fmt.Printf("Got on-call user %q (ID %s) for schedule %s\n", user.FullName, user.ID, schedule)
+		// Opsgenie usernames are email addresses.
+		onCallUsers = append(onCallUsers, oncallUser{
+			id:    user.ID,
+			name:  user.FullName,
+			email: user.Username,
+		})
+	}
+
+	return onCallUsers, nil
+}
+
+func (cl *opsgenieClient) getUser(ctx context.Context, username string) (opsgenieUser, error) {
+	cl.usersMu.Lock()
+	defer cl.usersMu.Unlock()
+
+	if user, ok := cl.users[username]; ok {
+		return user, nil
+	}
+
+	var user opsgenieUser
+	if err := cl.get(ctx, "/v2/users/"+url.PathEscape(username), nil, &user); err != nil {
+		return opsgenieUser{}, err
+	}
+	cl.users[username] = user
+
+	return user, nil
+}
+
+// get issues a GET request against the Opsgenie API and decodes the "data"
+// field of the response into out.
+func (cl *opsgenieClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	u := cl.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "GenieKey "+cl.apiKey)
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err := retryOnHTTPRateLimit("Opsgenie", func() error {
+		return doJSONRequest(ctx, cl.httpClient, http.MethodGet, u, header, nil, &envelope)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("failed to decode response data: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newFakeOpsgenieServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/schedules/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "GenieKey secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/schedules/Awesome-Primary":
+			if r.URL.Query().Get("identifierType") != "name" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, `{"data": {"id": "sched-1", "name": "Awesome-Primary"}}`)
+		case "/v2/schedules/sched-1/on-calls":
+			fmt.Fprint(w, `{"data": {"_parent": {"id": "sched-1"}, "onCallRecipients": ["jane@example.com", "john@example.com"]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "not found"}`)
+		}
+	})
+	mux.HandleFunc("/v2/users/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/users/jane@example.com":
+			fmt.Fprint(w, `{"data": {"id": "user-1", "username": "jane@example.com", "fullName": "Jane Doe"}}`)
+		case "/v2/users/john@example.com":
+			fmt.Fprint(w, `{"data": {"id": "user-2", "username": "john@example.com", "fullName": "John Doe"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOpsgenieClient(t *testing.T) {
+	srv := newFakeOpsgenieServer(t)
+	cl := newOpsgenieClient("secret", srv.URL)
+	ctx := context.Background()
+
+	schedule, err := cl.getSchedule(ctx, "", "Awesome-Primary")
+	if err != nil {
+		t.Fatalf("failed to get schedule: %s", err)
+	}
+	if schedule == nil {
+		t.Fatal("schedule not found")
+	}
+	if schedule.id != "sched-1" {
+		t.Errorf("got schedule ID %q, want %q", schedule.id, "sched-1")
+	}
+
+	missingSchedule, err := cl.getSchedule(ctx, "does-not-exist", "")
+	if err != nil {
+		t.Fatalf("failed to get missing schedule: %s", err)
+	}
+	if missingSchedule != nil {
+		t.Errorf("got schedule %s, want none", missingSchedule)
+	}
+
+	gotUsers, err := cl.getOnCallUsers(ctx, *schedule)
+	if err != nil {
+		t.Fatalf("failed to get on-call users: %s", err)
+	}
+	wantUsers := []oncallUser{
+		{id: "user-1", name: "Jane Doe", email: "jane@example.com"},
+		{id: "user-2", name: "John Doe", email: "john@example.com"},
+	}
+	if diff := cmp.Diff(wantUsers, gotUsers, cmp.AllowUnexported(oncallUser{})); diff != "" {
+		t.Errorf("on-call users mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -13,44 +13,25 @@ import (
 	"github.com/matryer/try"
 )
 
-type pdSchedules []pdSchedule
-
-func (schedules *pdSchedules) ensureSchedule(schedule pdSchedule) {
-	for _, sched := range *schedules {
-		if sched.id == schedule.id {
-			return
-		}
-	}
-	*schedules = append(*schedules, schedule)
-}
-
-type pdSchedule struct {
-	id         string
-	name       string
-	userGroups UserGroups
-}
-
-func (ps pdSchedule) String() string {
-	return fmt.Sprintf("{ID:%s Name:%q}", ps.id, ps.name)
-}
-
-func pagerDutyUserString(user pagerduty.User) string {
-	return fmt.Sprintf("ID: %s Name: %s Email: %s", user.ID, user.Name, user.Email)
-}
-
+// pagerDutyClient is the oncallProvider implementation for PagerDuty.
 type pagerDutyClient struct {
 	*pagerduty.Client
 	pdSchedulesByNameOnce sync.Once
-	pdSchedulesByName     map[string]pdSchedule
+	pdSchedulesByName     map[string]oncallSchedule
+
+	usersMu sync.Mutex
+	// users caches users by ID.
+	users map[string]oncallUser
 }
 
 func newPagerDutyClient(token string) *pagerDutyClient {
 	return &pagerDutyClient{
 		Client: pagerduty.NewClient(token),
+		users:  map[string]oncallUser{},
 	}
 }
 
-func (cl *pagerDutyClient) getSchedule(ctx context.Context, id, name string) (*pdSchedule, error) {
+func (cl *pagerDutyClient) getSchedule(ctx context.Context, id, name string) (*oncallSchedule, error) {
 	if id != "" {
 		schedule, err := cl.getScheduleByID(ctx, id)
 		if err != nil {
@@ -67,7 +48,7 @@ func (cl *pagerDutyClient) getSchedule(ctx context.Context, id, name string) (*p
 	return schedule, nil
 }
 
-func (cl *pagerDutyClient) getScheduleByID(ctx context.Context, scheduleID string) (*pdSchedule, error) {
+func (cl *pagerDutyClient) getScheduleByID(ctx context.Context, scheduleID string) (*oncallSchedule, error) {
 	if scheduleID == "" {
 		return nil, errors.New("schedule ID is missing")
 	}
@@ -87,13 +68,13 @@ func (cl *pagerDutyClient) getScheduleByID(ctx context.Context, scheduleID strin
 		return nil, nil
 	}
 
-	return &pdSchedule{
+	return &oncallSchedule{
 		id:   schedule.ID,
 		name: schedule.Name,
 	}, nil
 }
 
-func (cl *pagerDutyClient) getScheduleByName(ctx context.Context, scheduleName string) (*pdSchedule, error) {
+func (cl *pagerDutyClient) getScheduleByName(ctx context.Context, scheduleName string) (*oncallSchedule, error) {
 	if scheduleName == "" {
 		return nil, errors.New("schedule name is missing")
 	}
@@ -106,16 +87,16 @@ func (cl *pagerDutyClient) getScheduleByName(ctx context.Context, scheduleName s
 		return nil, fmt.Errorf("failed to get all schedules by name: %s", err)
 	}
 
-	pdSchedule, ok := cl.pdSchedulesByName[scheduleName]
+	schedule, ok := cl.pdSchedulesByName[scheduleName]
 	if !ok {
 		return nil, nil
 	}
 
-	return &pdSchedule, nil
+	return &schedule, nil
 }
 
-func (cl *pagerDutyClient) getAllSchedulesByName(ctx context.Context) (map[string]pdSchedule, error) {
-	pdSchedules := map[string]pdSchedule{}
+func (cl *pagerDutyClient) getAllSchedulesByName(ctx context.Context) (map[string]oncallSchedule, error) {
+	pdSchedules := map[string]oncallSchedule{}
 	opts := pagerduty.ListSchedulesOptions{
 		Limit: 100,
 	}
@@ -133,7 +114,7 @@ func (cl *pagerDutyClient) getAllSchedulesByName(ctx context.Context) (map[strin
 		}
 
 		for _, schedule := range schedulesResp.Schedules {
-			pdSchedules[schedule.Name] = pdSchedule{
+			pdSchedules[schedule.Name] = oncallSchedule{
 				id:   schedule.ID,
 				name: schedule.Name,
 			}
@@ -148,25 +129,327 @@ func (cl *pagerDutyClient) getAllSchedulesByName(ctx context.Context) (map[strin
 	return pdSchedules, nil
 }
 
-func (cl *pagerDutyClient) getOnCallUser(ctx context.Context, schedule pdSchedule) (pagerduty.User, error) {
+func (cl *pagerDutyClient) getEscalationPolicy(ctx context.Context, id, name string, levels []uint) (*oncallSchedule, error) {
+	if len(levels) == 0 {
+		levels = []uint{1}
+	}
+
+	if id != "" {
+		escalationPolicy, err := cl.getEscalationPolicyByID(ctx, id, levels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get escalation policy by ID: %s", err)
+		}
+		return escalationPolicy, nil
+	}
+
+	escalationPolicy, err := cl.getEscalationPolicyByName(ctx, name, levels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get escalation policy by name: %s", err)
+	}
+
+	return escalationPolicy, nil
+}
+
+func (cl *pagerDutyClient) getEscalationPolicyByID(ctx context.Context, escalationPolicyID string, levels []uint) (*oncallSchedule, error) {
+	if escalationPolicyID == "" {
+		return nil, errors.New("escalation policy ID is missing")
+	}
+
+	fmt.Printf("Looking up escalation policy by ID %s\n", escalationPolicyID)
+	var escalationPolicy *pagerduty.EscalationPolicy
+	rErr := retryOnPagerDutyRateLimit(func() error {
+		var err error
+		escalationPolicy, err = cl.GetEscalationPolicyWithContext(ctx, escalationPolicyID, &pagerduty.GetEscalationPolicyOptions{})
+		return err
+	})
+	if rErr != nil {
+		return nil, rErr
+	}
+
+	if escalationPolicy == nil {
+		return nil, nil
+	}
+
+	return &oncallSchedule{
+		id:               escalationPolicy.ID,
+		name:             escalationPolicy.Name,
+		escalationLevels: levels,
+	}, nil
+}
+
+func (cl *pagerDutyClient) getEscalationPolicyByName(ctx context.Context, escalationPolicyName string, levels []uint) (*oncallSchedule, error) {
+	if escalationPolicyName == "" {
+		return nil, errors.New("escalation policy name is missing")
+	}
+
+	opts := pagerduty.ListEscalationPoliciesOptions{
+		Limit: 100,
+		Query: escalationPolicyName,
+	}
+	fmt.Printf("Looking up escalation policy by name %q\n", escalationPolicyName)
+	for {
+		var escalationPoliciesResp *pagerduty.ListEscalationPoliciesResponse
+		rErr := retryOnPagerDutyRateLimit(func() error {
+			var err error
+			escalationPoliciesResp, err = cl.ListEscalationPoliciesWithContext(ctx, opts)
+			return err
+		})
+		if rErr != nil {
+			return nil, rErr
+		}
+
+		// The query parameter matches partially, so we need to look for the
+		// exact name ourselves.
+		for _, escalationPolicy := range escalationPoliciesResp.EscalationPolicies {
+			if escalationPolicy.Name == escalationPolicyName {
+				return &oncallSchedule{
+					id:               escalationPolicy.ID,
+					name:             escalationPolicy.Name,
+					escalationLevels: levels,
+				}, nil
+			}
+		}
+
+		if !escalationPoliciesResp.APIListObject.More {
+			break
+		}
+		opts.Offset = opts.Offset + opts.Limit
+	}
+
+	return nil, nil
+}
+
+func (cl *pagerDutyClient) getOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]oncallUser, error) {
+	var (
+		pdUsers []pagerduty.User
+		ends    map[string]time.Time
+		err     error
+	)
+	if schedule.isEscalationPolicy() {
+		pdUsers, ends, err = cl.getEscalationPolicyOnCallUsers(ctx, schedule)
+	} else {
+		pdUsers, err = cl.getScheduleOnCallUsers(ctx, schedule)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Shift ends require looking at the rendered schedule, which is only worth
+	// it if anybody is interested in them. They are informational only, so a
+	// failure to get them must not fail the sync.
+	if !schedule.isEscalationPolicy() && schedule.withShiftEnds && len(pdUsers) > 0 {
+		ends, err = cl.getScheduleShiftEnds(ctx, schedule)
+		if err != nil {
+			fmt.Printf("Failed to get shift ends for schedule %s, leaving them unknown: %s\n", schedule, err)
+		}
+	}
+
+	if len(pdUsers) == 0 {
+		fmt.Printf("Got no on-call users for schedule %s\n", schedule)
+	}
+	onCallUsers := make([]oncallUser, 0, len(pdUsers))
+	for _, pdUser := range pdUsers {
+		fmt.Printf("Got on-call user %q (ID %s) for schedule %s\n", pdUser.Name, pdUser.ID, schedule)
+		onCallUsers = append(onCallUsers, oncallUser{
+			id:    pdUser.ID,
+			name:  pdUser.Name,
+			email: pdUser.Email,
+			until: ends[pdUser.ID],
+		})
+	}
+
+	return onCallUsers, nil
+}
+
+func (cl *pagerDutyClient) getScheduleOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]pagerduty.User, error) {
 	now := time.Now()
 	fmt.Printf("Getting on-call users for schedule %s\n", schedule)
-	onCallUsers, err := cl.ListOnCallUsersWithContext(ctx, schedule.id, pagerduty.ListOnCallUsersOptions{
+	return cl.ListOnCallUsersWithContext(ctx, schedule.id, pagerduty.ListOnCallUsersOptions{
 		Since: now.Add(-1 * time.Second).Format(time.RFC3339),
 		Until: now.Format(time.RFC3339),
 	})
+}
+
+// getScheduleShiftEnds returns the end of the current shift by user ID,
+// looking ahead up to two weeks. Consecutive entries of the same user count as
+// one shift.
+func (cl *pagerDutyClient) getScheduleShiftEnds(ctx context.Context, schedule oncallSchedule) (map[string]time.Time, error) {
+	now := time.Now()
+	shifts, err := cl.getRenderedShifts(ctx, schedule, now, now.Add(14*24*time.Hour))
 	if err != nil {
-		return pagerduty.User{}, err
+		return nil, err
+	}
+
+	// Extend a current shift by the shifts of the same user that directly
+	// follow it.
+	ends := map[string]time.Time{}
+	for _, shift := range shifts {
+		if prevEnd, ok := ends[shift.userID]; ok && prevEnd.Equal(shift.start) {
+			ends[shift.userID] = shift.end
+			continue
+		}
+		if !shift.start.After(now) && shift.end.After(now) {
+			ends[shift.userID] = shift.end
+		}
+	}
+
+	return ends, nil
+}
+
+// getUpcomingShifts implements upcomingShiftProvider.
+func (cl *pagerDutyClient) getUpcomingShifts(ctx context.Context, schedule oncallSchedule, until time.Time) ([]oncallShift, error) {
+	if schedule.isEscalationPolicy() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	// Start looking a bit in the past to recognize shifts that continue the
+	// current one.
+	renderedShifts, err := cl.getRenderedShifts(ctx, schedule, now.Add(-1*time.Minute), until)
+	if err != nil {
+		return nil, err
+	}
+
+	var shifts []oncallShift
+	for i, rShift := range renderedShifts {
+		if !rShift.start.After(now) {
+			continue
+		}
+		if i > 0 && renderedShifts[i-1].userID == rShift.userID && renderedShifts[i-1].end.Equal(rShift.start) {
+			continue
+		}
+
+		user, err := cl.getUser(ctx, rShift.userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %s", rShift.userID, err)
+		}
+		shifts = append(shifts, oncallShift{
+			user:  user,
+			start: rShift.start,
+			end:   rShift.end,
+		})
+	}
+
+	return shifts, nil
+}
+
+type pagerDutyRenderedShift struct {
+	userID string
+	start  time.Time
+	end    time.Time
+}
+
+// getRenderedShifts returns the entries of the final schedule layer between
+// since and until in chronological order.
+func (cl *pagerDutyClient) getRenderedShifts(ctx context.Context, schedule oncallSchedule, since, until time.Time) ([]pagerDutyRenderedShift, error) {
+	var pdSchedule *pagerduty.Schedule
+	rErr := retryOnPagerDutyRateLimit(func() error {
+		var err error
+		pdSchedule, err = cl.GetScheduleWithContext(ctx, schedule.id, pagerduty.GetScheduleOptions{
+			Since: since.Format(time.RFC3339),
+			Until: until.Format(time.RFC3339),
+		})
+		return err
+	})
+	if rErr != nil {
+		return nil, fmt.Errorf("failed to get rendered schedule: %s", rErr)
+	}
+
+	var shifts []pagerDutyRenderedShift
+	for _, entry := range pdSchedule.FinalSchedule.RenderedScheduleEntries {
+		start, err := time.Parse(time.RFC3339, entry.Start)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse start of schedule entry: %s", err)
+		}
+		end, err := time.Parse(time.RFC3339, entry.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse end of schedule entry: %s", err)
+		}
+		shifts = append(shifts, pagerDutyRenderedShift{
+			userID: entry.User.ID,
+			start:  start,
+			end:    end,
+		})
+	}
+
+	return shifts, nil
+}
+
+func (cl *pagerDutyClient) getUser(ctx context.Context, id string) (oncallUser, error) {
+	cl.usersMu.Lock()
+	defer cl.usersMu.Unlock()
+
+	if user, ok := cl.users[id]; ok {
+		return user, nil
+	}
+
+	var pdUser *pagerduty.User
+	rErr := retryOnPagerDutyRateLimit(func() error {
+		var err error
+		pdUser, err = cl.GetUserWithContext(ctx, id, pagerduty.GetUserOptions{})
+		return err
+	})
+	if rErr != nil {
+		return oncallUser{}, rErr
+	}
+
+	user := oncallUser{
+		id:    pdUser.ID,
+		name:  pdUser.Name,
+		email: pdUser.Email,
 	}
+	cl.users[id] = user
+	return user, nil
+}
 
-	if len(onCallUsers) != 1 {
-		return pagerduty.User{}, fmt.Errorf("unexpected number of on-call users: %d", len(onCallUsers))
+// getEscalationPolicyOnCallUsers returns the users currently on call for the
+// selected levels of an escalation policy. This includes users targeted by the
+// escalation policy directly, i.e., without an intermediate schedule.
+func (cl *pagerDutyClient) getEscalationPolicyOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]pagerduty.User, map[string]time.Time, error) {
+	wantLevels := map[uint]bool{}
+	for _, level := range schedule.escalationLevels {
+		wantLevels[level] = true
 	}
 
-	onCallUser := onCallUsers[0]
-	fmt.Printf("Got on-call user %q (ID %s) for schedule %s\n", onCallUser.Name, onCallUser.ID, schedule)
+	opts := pagerduty.ListOnCallOptions{
+		Limit:               100,
+		Includes:            []string{"users"},
+		EscalationPolicyIDs: []string{schedule.id},
+	}
+	fmt.Printf("Getting on-call users for escalation policy %s\n", schedule)
+	var onCallUsers []pagerduty.User
+	ends := map[string]time.Time{}
+	foundUserIDs := map[string]bool{}
+	for {
+		var onCallsResp *pagerduty.ListOnCallsResponse
+		rErr := retryOnPagerDutyRateLimit(func() error {
+			var err error
+			onCallsResp, err = cl.ListOnCallsWithContext(ctx, opts)
+			return err
+		})
+		if rErr != nil {
+			return nil, nil, rErr
+		}
+
+		for _, onCall := range onCallsResp.OnCalls {
+			if !wantLevels[onCall.EscalationLevel] || foundUserIDs[onCall.User.ID] {
+				continue
+			}
+			foundUserIDs[onCall.User.ID] = true
+			onCallUsers = append(onCallUsers, onCall.User)
+			// Users on call indefinitely have no end.
+			if end, err := time.Parse(time.RFC3339, onCall.End); err == nil {
+				ends[onCall.User.ID] = end
+			}
+		}
+
+		if !onCallsResp.APIListObject.More {
+			break
+		}
+		opts.Offset = opts.Offset + opts.Limit
+	}
 
-	return onCallUser, nil
+	return onCallUsers, ends, nil
 }
 
 func retryOnPagerDutyRateLimit(f func() error) error {
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pagerduty"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPagerDutyClientGetOnCallUsersShiftEnds(t *testing.T) {
+	end := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name             string
+		withShiftEnds    bool
+		renderedFails    bool
+		wantRenderedReqs int
+		wantUntil        time.Time
+	}{
+		{
+			name: "shift ends not needed",
+		},
+		{
+			name:             "shift ends needed",
+			withShiftEnds:    true,
+			wantRenderedReqs: 1,
+			wantUntil:        end,
+		},
+		{
+			name:             "shift ends unavailable",
+			withShiftEnds:    true,
+			renderedFails:    true,
+			wantRenderedReqs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var renderedReqs int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/schedules/S1/users":
+					fmt.Fprint(w, `{"users": [{"id": "PD1", "name": "Jane Doe", "email": "jane@example.com"}]}`)
+				case "/schedules/S1":
+					renderedReqs++
+					if tt.renderedFails {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					start := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+					fmt.Fprintf(w, `{"schedule": {"id": "S1", "final_schedule": {"rendered_schedule_entries": [{"start": %q, "end": %q, "user": {"id": "PD1"}}]}}}`, start, end.Format(time.RFC3339))
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			cl := &pagerDutyClient{
+				Client: pagerduty.NewClient("token", pagerduty.WithAPIEndpoint(srv.URL)),
+			}
+			gotUsers, err := cl.getOnCallUsers(context.Background(), oncallSchedule{id: "S1", name: "Primary", withShiftEnds: tt.withShiftEnds})
+			if err != nil {
+				t.Fatalf("failed to get on-call users: %s", err)
+			}
+
+			wantUsers := []oncallUser{{id: "PD1", name: "Jane Doe", email: "jane@example.com", until: tt.wantUntil}}
+			if diff := cmp.Diff(wantUsers, gotUsers, cmp.AllowUnexported(oncallUser{})); diff != "" {
+				t.Errorf("on-call users mismatch (-want +got):\n%s", diff)
+			}
+			if renderedReqs != tt.wantRenderedReqs {
+				t.Errorf("got %d rendered schedule request(s), want %d", renderedReqs, tt.wantRenderedReqs)
+			}
+		})
+	}
+}
+
+func TestPagerDutyClientGetScheduleShiftEndsMergesConsecutiveEntries(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(hours int) string {
+		return now.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schedules/S1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"schedule": {"id": "S1", "final_schedule": {"rendered_schedule_entries": [
+			{"start": %q, "end": %q, "user": {"id": "PD1"}},
+			{"start": %q, "end": %q, "user": {"id": "PD1"}},
+			{"start": %q, "end": %q, "user": {"id": "PD2"}},
+			{"start": %q, "end": %q, "user": {"id": "PD1"}}
+		]}}}`, at(-1), at(1), at(1), at(2), at(2), at(3), at(3), at(4))
+	}))
+	defer srv.Close()
+
+	cl := &pagerDutyClient{
+		Client: pagerduty.NewClient("token", pagerduty.WithAPIEndpoint(srv.URL)),
+	}
+	gotEnds, err := cl.getScheduleShiftEnds(context.Background(), oncallSchedule{id: "S1", name: "Primary"})
+	if err != nil {
+		t.Fatalf("failed to get shift ends: %s", err)
+	}
+
+	wantEnds := map[string]time.Time{"PD1": now.Add(2 * time.Hour)}
+	if diff := cmp.Diff(wantEnds, gotEnds); diff != "" {
+		t.Errorf("shift ends mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -5,6 +5,7 @@ import "time"
 type params struct {
 	config                string
 	schedules             []string
+	escalationPolicies    []string
 	channelName           string
 	channelID             string
 	tmplString            string
@@ -14,4 +15,5 @@ type params struct {
 	daemon                bool
 	daemonUpdateFrequency time.Duration
 	failFast              bool
+	stateFile             string
 }
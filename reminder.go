@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// defaultReminderTemplate renders the shift start in the reader's time zone
+// through Slack's date formatting.
+const defaultReminderTemplate = `Heads-up: your on-call shift for {{.Schedule}} starts <!date^{{.Start.Unix}}^{date_short_pretty} at {time}|at {{.Start.Format "2006-01-02 15:04 MST"}}>.`
+
+type runReminder struct {
+	before time.Duration
+	tmpl   *template.Template
+}
+
+// reminderTemplateData is passed to the reminder template.
+type reminderTemplateData struct {
+	Schedule string
+	// Name is the name of the reminded user.
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+func createReminder(cfgReminder ConfigReminder) (*runReminder, error) {
+	tmplString := cfgReminder.Template
+	if tmplString == "" {
+		tmplString = defaultReminderTemplate
+	}
+	tmpl, err := template.New("reminder").Parse(tmplString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %q: %s", tmplString, err)
+	}
+
+	return &runReminder{
+		before: cfgReminder.Before,
+		tmpl:   tmpl,
+	}, nil
+}
+
+// runReminder sends a direct message to users whose shift starts within the
+// configured duration. Each shift is reminded of once only. A failed reminder
+// does not keep the remaining ones from being sent.
+func (s *syncer) runReminder(ctx context.Context, reminder runReminder, slackSync runSlackSync) error {
+	now := time.Now()
+	for key, start := range s.sentReminders {
+		if start.Before(now) {
+			delete(s.sentReminders, key)
+		}
+	}
+
+	var errs []error
+	for _, schedule := range slackSync.schedules {
+		provider, ok := schedule.provider.(upcomingShiftProvider)
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("Getting upcoming shifts for schedule %s\n", schedule)
+		shifts, err := provider.getUpcomingShifts(ctx, schedule, now.Add(reminder.before))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get upcoming shifts for schedule %q: %s", schedule.name, err))
+			continue
+		}
+
+		for _, shift := range shifts {
+			key := fmt.Sprintf("%s/%s/%s", schedule.id, shift.user.id, shift.start.UTC().Format(time.RFC3339))
+			if _, ok := s.sentReminders[key]; ok {
+				continue
+			}
+
+			slUser := slackSync.slack.users.findByOncallUser(shift.user)
+			if slUser == nil {
+				errs = append(errs, fmt.Errorf("failed to find Slack user for on-call user %s", shift.user))
+				continue
+			}
+
+			var buf bytes.Buffer
+			data := reminderTemplateData{
+				Schedule: schedule.name,
+				Name:     shift.user.name,
+				Start:    shift.start,
+				End:      shift.end,
+			}
+			if err := reminder.tmpl.Execute(&buf, data); err != nil {
+				errs = append(errs, fmt.Errorf("failed to render template: %s", err))
+				continue
+			}
+
+			fmt.Printf("Reminding user %s of shift starting at %s\n", slUser.id, shift.start)
+			if err := slackSync.slack.client.postMessage(ctx, slUser.id, buf.String(), slackSync.dryRun); err != nil {
+				errs = append(errs, fmt.Errorf("failed to send reminder to user %s: %s", slUser.id, err))
+				continue
+			}
+			if !slackSync.dryRun {
+				s.sentReminders[key] = shift.start
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+// fakeShiftProvider returns a fixed set of upcoming shifts and, optionally,
+// an error for the current on-call users.
+type fakeShiftProvider struct {
+	shifts    []oncallShift
+	onCallErr error
+}
+
+func (fsp *fakeShiftProvider) getSchedule(context.Context, string, string) (*oncallSchedule, error) {
+	return nil, nil
+}
+
+func (fsp *fakeShiftProvider) getOnCallUsers(context.Context, oncallSchedule) ([]oncallUser, error) {
+	return nil, fsp.onCallErr
+}
+
+func (fsp *fakeShiftProvider) getUpcomingShifts(_ context.Context, _ oncallSchedule, until time.Time) ([]oncallShift, error) {
+	var shifts []oncallShift
+	for _, shift := range fsp.shifts {
+		if shift.start.Before(until) {
+			shifts = append(shifts, shift)
+		}
+	}
+	return shifts, nil
+}
+
+func TestRunReminder(t *testing.T) {
+	type message struct {
+		Channel string
+		Text    string
+	}
+	var gotMessages []message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		gotMessages = append(gotMessages, message{Channel: r.PostForm.Get("channel"), Text: r.PostForm.Get("text")})
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": r.PostForm.Get("channel"), "ts": "1.0"})
+	}))
+	defer srv.Close()
+
+	now := time.Now().Truncate(time.Second)
+	provider := &fakeShiftProvider{
+		shifts: []oncallShift{
+			{user: oncallUser{id: "PD1", name: "Jane", email: "jane@example.com"}, start: now.Add(30 * time.Minute), end: now.Add(90 * time.Minute)},
+			{user: oncallUser{id: "PD2", name: "John", email: "john@example.com"}, start: now.Add(90 * time.Minute), end: now.Add(150 * time.Minute)},
+			{user: oncallUser{id: "PD1", name: "Jane", email: "jane@example.com"}, start: now.Add(150 * time.Minute), end: now.Add(210 * time.Minute)},
+		},
+	}
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	reminder, err := createReminder(ConfigReminder{
+		Before:   2 * time.Hour,
+		Template: "Hi {{.Name}}, your {{.Schedule}} shift lasts {{.End.Sub .Start}}.",
+	})
+	if err != nil {
+		t.Fatalf("failed to create reminder: %s", err)
+	}
+	slackSync := runSlackSync{
+		name:      "team",
+		slack:     conn,
+		schedules: []oncallSchedule{{id: "S1", name: "Primary", provider: provider}},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.runReminder(context.Background(), *reminder, slackSync); err != nil {
+			t.Fatalf("run %d: failed to run reminder: %s", i, err)
+		}
+	}
+
+	wantMessages := []message{
+		{Channel: "U1", Text: "Hi Jane, your Primary shift lasts 1h0m0s."},
+		{Channel: "U2", Text: "Hi John, your Primary shift lasts 1h0m0s."},
+	}
+	if diff := cmp.Diff(wantMessages, gotMessages); diff != "" {
+		t.Errorf("messages mismatch (-want +got):\n%s", diff)
+	}
+	if len(s.sentReminders) != 2 {
+		t.Errorf("got %d remembered reminders, want 2", len(s.sentReminders))
+	}
+
+	// A restarted syncer that restored its state must not remind again.
+	restored := newSyncer(syncerParams{})
+	for key, start := range s.sentReminders {
+		restored.sentReminders[key] = start
+	}
+	if err := restored.runReminder(context.Background(), *reminder, slackSync); err != nil {
+		t.Fatalf("failed to run reminder after restart: %s", err)
+	}
+	if len(gotMessages) != len(wantMessages) {
+		t.Errorf("got %d messages after restart, want %d", len(gotMessages), len(wantMessages))
+	}
+}
+
+func TestRunRemindsDespiteFailures(t *testing.T) {
+	var gotChannels []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		gotChannels = append(gotChannels, r.PostForm.Get("channel"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": r.PostForm.Get("channel"), "ts": "1.0"})
+	}))
+	defer srv.Close()
+
+	now := time.Now().Truncate(time.Second)
+	provider := &fakeShiftProvider{
+		shifts: []oncallShift{
+			{user: oncallUser{id: "PD2", name: "John", email: "john@example.com"}, start: now.Add(30 * time.Minute), end: now.Add(90 * time.Minute)},
+			{user: oncallUser{id: "PD1", name: "Jane", email: "jane@example.com"}, start: now.Add(90 * time.Minute), end: now.Add(150 * time.Minute)},
+		},
+		onCallErr: errors.New("provider unavailable"),
+	}
+
+	reminder, err := createReminder(ConfigReminder{Before: 2 * time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create reminder: %s", err)
+	}
+	// John is unknown to Slack, so his reminder fails.
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users:  slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	slackSyncs := []runSlackSync{
+		{
+			name:      "team",
+			slack:     conn,
+			schedules: []oncallSchedule{{id: "S1", name: "Primary", provider: provider}},
+			reminder:  reminder,
+		},
+	}
+
+	st := newStateStore(filepath.Join(t.TempDir(), "state.json"))
+	s := newSyncer(syncerParams{stateStore: st})
+	if err := s.Run(context.Background(), slackSyncs, true); err == nil {
+		t.Fatal("got no error for failing sync, want one")
+	}
+
+	if diff := cmp.Diff([]string{"U1"}, gotChannels); diff != "" {
+		t.Errorf("reminded users mismatch (-want +got):\n%s", diff)
+	}
+	restored := newSyncer(syncerParams{stateStore: st})
+	if err := restored.restoreState(); err != nil {
+		t.Fatalf("failed to restore state: %s", err)
+	}
+	if len(restored.sentReminders) != 1 {
+		t.Errorf("got %d persisted reminders, want 1", len(restored.sentReminders))
+	}
+}
+
+func TestCreateReminderDefaultTemplate(t *testing.T) {
+	reminder, err := createReminder(ConfigReminder{Before: time.Hour})
+	if err != nil {
+		t.Fatalf("failed to create reminder: %s", err)
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := reminder.tmpl.Execute(&buf, reminderTemplateData{Schedule: "Primary", Start: start}); err != nil {
+		t.Fatalf("failed to render template: %s", err)
+	}
+
+	want := "Heads-up: your on-call shift for Primary starts <!date^1704704400^{date_short_pretty} at {time}|at 2024-01-08 09:00 UTC>."
+	if got := buf.String(); got != want {
+		t.Errorf("got message %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const defaultRosterTitle = "On-call roster"
+
+type runRoster struct {
+	title string
+}
+
+// rosterMessage identifies the roster message of a sync and the content it
+// was last rendered with.
+type rosterMessage struct {
+	TS string `json:"ts"`
+	// Digest is the SHA-256 digest of the rendered blocks.
+	Digest string `json:"digest"`
+	// Pinned is true once the message was pinned successfully.
+	Pinned bool `json:"pinned"`
+}
+
+func createRoster(cfgRoster ConfigRoster) *runRoster {
+	title := cfgRoster.Title
+	if title == "" {
+		title = defaultRosterTitle
+	}
+	return &runRoster{
+		title: title,
+	}
+}
+
+// runRoster keeps a pinned message listing the on-call users of each schedule
+// in the Slack channel up-to-date. The message is posted once and edited in
+// place afterwards; it is only reposted if it was deleted.
+func (s *syncer) runRoster(ctx context.Context, roster runRoster, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	blocks, err := s.renderRoster(roster, slackSync, onCalls)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(blocks)
+	if err != nil {
+		return fmt.Errorf("failed to encode blocks: %s", err)
+	}
+	sum := sha256.Sum256(b)
+	digest := hex.EncodeToString(sum[:])
+
+	msg := s.rosters[slackSync.name]
+	if msg.TS != "" {
+		if msg.Digest == digest {
+			fmt.Println("Roster message already set correctly")
+			return s.pinRoster(ctx, slackSync, msg)
+		}
+
+		fmt.Printf("Updating roster message %s\n", msg.TS)
+		err := slackSync.slack.client.updateBlocks(ctx, slackSync.slackChannelID, msg.TS, roster.title, blocks, slackSync.dryRun)
+		if err == nil {
+			if !slackSync.dryRun {
+				msg.Digest = digest
+				s.rosters[slackSync.name] = msg
+			}
+			return s.pinRoster(ctx, slackSync, msg)
+		}
+		if !isSlackError(err, "message_not_found") {
+			return fmt.Errorf("failed to update roster message: %s", err)
+		}
+		fmt.Printf("Roster message %s not found, posting a new one\n", msg.TS)
+	}
+
+	ts, err := slackSync.slack.client.postBlocks(ctx, slackSync.slackChannelID, roster.title, blocks, slackSync.dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to post roster message: %s", err)
+	}
+	if slackSync.dryRun {
+		return nil
+	}
+	msg = rosterMessage{TS: ts, Digest: digest}
+	s.rosters[slackSync.name] = msg
+
+	return s.pinRoster(ctx, slackSync, msg)
+}
+
+// pinRoster pins the given roster message unless it was pinned before. A
+// failed pin is retried on the next run.
+func (s *syncer) pinRoster(ctx context.Context, slackSync runSlackSync, msg rosterMessage) error {
+	if msg.Pinned {
+		return nil
+	}
+
+	if err := slackSync.slack.client.pinMessage(ctx, slackSync.slackChannelID, msg.TS, slackSync.dryRun); err != nil {
+		return fmt.Errorf("failed to pin roster message: %s", err)
+	}
+	if !slackSync.dryRun {
+		msg.Pinned = true
+		s.rosters[slackSync.name] = msg
+	}
+
+	return nil
+}
+
+// renderRoster returns a header block followed by one section per schedule.
+func (s *syncer) renderRoster(roster runRoster, slackSync runSlackSync, onCalls []scheduleOnCall) ([]slack.Block, error) {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, roster.title, false, false)),
+	}
+
+	for _, onCall := range onCalls {
+		slUserIDs, err := slackSync.slackUserIDsFor(onCall.users)
+		if err != nil {
+			return nil, err
+		}
+
+		onCallText := "_nobody_"
+		if len(slUserIDs) > 0 {
+			onCallText = mentions(slUserIDs)
+		}
+
+		untilText := "_unknown_"
+		var until time.Time
+		for _, user := range onCall.users {
+			if !user.until.IsZero() && (until.IsZero() || user.until.Before(until)) {
+				until = user.until
+			}
+		}
+		if !until.IsZero() {
+			untilText = fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", until.Unix(), until.UTC().Format("2006-01-02 15:04 MST"))
+		}
+
+		fields := []*slack.TextBlockObject{
+			rosterField("Schedule", onCall.schedule.name),
+			rosterField("On call", onCallText),
+			rosterField("Until", untilText),
+		}
+		if len(onCall.schedule.userGroups) > 0 {
+			var handles []string
+			for _, userGroup := range onCall.schedule.userGroups {
+				handles = append(handles, fmt.Sprintf("<!subteam^%s|@%s>", userGroup.ID, userGroup.Handle))
+			}
+			fields = append(fields, rosterField("User group", strings.Join(handles, ", ")))
+		}
+
+		blocks = append(blocks, slack.NewSectionBlock(nil, fields, nil))
+	}
+
+	return blocks, nil
+}
+
+func rosterField(name, value string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", name, value), false, false)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+// fakeSlackMessages is a minimal stand-in for the Slack chat and pins APIs.
+type fakeSlackMessages struct {
+	// messages holds the blocks of the messages by timestamp.
+	messages map[string]string
+	pinned   []string
+	pinFails bool
+	requests []string
+	nextTS   int
+}
+
+func (fsm *fakeSlackMessages) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		if got := r.PostForm.Get("channel"); got != "C1" {
+			t.Errorf("got channel %q, want C1", got)
+		}
+		fsm.requests = append(fsm.requests, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			fsm.nextTS++
+			ts := fmt.Sprintf("100%d.0", fsm.nextTS)
+			fsm.messages[ts] = r.PostForm.Get("blocks")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": "C1", "ts": ts})
+		case "/chat.update":
+			ts := r.PostForm.Get("ts")
+			if _, ok := fsm.messages[ts]; !ok {
+				fmt.Fprint(w, `{"ok": false, "error": "message_not_found"}`)
+				return
+			}
+			fsm.messages[ts] = r.PostForm.Get("blocks")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": "C1", "ts": ts})
+		case "/pins.add":
+			if fsm.pinFails {
+				fmt.Fprint(w, `{"ok": false, "error": "not_pinnable"}`)
+				return
+			}
+			fsm.pinned = append(fsm.pinned, r.PostForm.Get("timestamp"))
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestRunRoster(t *testing.T) {
+	fsm := &fakeSlackMessages{messages: map[string]string{}}
+	srv := httptest.NewServer(fsm.handler(t))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	roster := createRoster(ConfigRoster{})
+	slackSync := runSlackSync{name: "team", slack: conn, slackChannelID: "C1"}
+	schedule := oncallSchedule{
+		id:         "S1",
+		name:       "Primary",
+		userGroups: UserGroups{{ID: "G1", Handle: "oncall"}},
+	}
+	until := time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC)
+	onCalls := func(email string) []scheduleOnCall {
+		return []scheduleOnCall{
+			{schedule: schedule, users: []oncallUser{{email: email, until: until}}},
+		}
+	}
+
+	steps := []struct {
+		name         string
+		onCalls      []scheduleOnCall
+		deleteTS     string
+		pinFails     bool
+		wantErr      bool
+		wantRequests []string
+		wantTS       string
+	}{
+		{
+			name:         "post and pin message",
+			onCalls:      onCalls("jane@example.com"),
+			wantRequests: []string{"/chat.postMessage", "/pins.add"},
+			wantTS:       "1001.0",
+		},
+		{
+			name:    "keep unchanged message",
+			onCalls: onCalls("jane@example.com"),
+			wantTS:  "1001.0",
+		},
+		{
+			name:         "update changed message",
+			onCalls:      onCalls("john@example.com"),
+			wantRequests: []string{"/chat.update"},
+			wantTS:       "1001.0",
+		},
+		{
+			name:         "repost deleted message",
+			onCalls:      onCalls("jane@example.com"),
+			deleteTS:     "1001.0",
+			wantRequests: []string{"/chat.update", "/chat.postMessage", "/pins.add"},
+			wantTS:       "1002.0",
+		},
+		{
+			name:         "fail to pin reposted message",
+			onCalls:      onCalls("john@example.com"),
+			deleteTS:     "1002.0",
+			pinFails:     true,
+			wantErr:      true,
+			wantRequests: []string{"/chat.update", "/chat.postMessage", "/pins.add"},
+			wantTS:       "1003.0",
+		},
+		{
+			name:         "retry failed pin",
+			onCalls:      onCalls("john@example.com"),
+			wantRequests: []string{"/pins.add"},
+			wantTS:       "1003.0",
+		},
+	}
+
+	for _, step := range steps {
+		fsm.requests = nil
+		if step.deleteTS != "" {
+			delete(fsm.messages, step.deleteTS)
+		}
+		fsm.pinFails = step.pinFails
+		err := s.runRoster(context.Background(), *roster, slackSync, step.onCalls)
+		if gotErr := err != nil; gotErr != step.wantErr {
+			t.Fatalf("step %q: got error %v, want error: %t", step.name, err, step.wantErr)
+		}
+		if diff := cmp.Diff(step.wantRequests, fsm.requests); diff != "" {
+			t.Errorf("step %q: requests mismatch (-want +got):\n%s", step.name, diff)
+		}
+		if got := s.rosters["team"].TS; got != step.wantTS {
+			t.Errorf("step %q: got remembered timestamp %q, want %q", step.name, got, step.wantTS)
+		}
+	}
+
+	if diff := cmp.Diff([]string{"1001.0", "1002.0", "1003.0"}, fsm.pinned); diff != "" {
+		t.Errorf("pinned messages mismatch (-want +got):\n%s", diff)
+	}
+
+	var blocks slack.Blocks
+	if err := json.Unmarshal([]byte(fsm.messages["1003.0"]), &blocks); err != nil {
+		t.Fatalf("failed to decode blocks: %s", err)
+	}
+	if len(blocks.BlockSet) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(blocks.BlockSet))
+	}
+	section, ok := blocks.BlockSet[1].(*slack.SectionBlock)
+	if !ok {
+		t.Fatalf("got block of type %T, want section", blocks.BlockSet[1])
+	}
+	var gotFields []string
+	for _, field := range section.Fields {
+		gotFields = append(gotFields, field.Text)
+	}
+	wantFields := []string{
+		"*Schedule*\nPrimary",
+		"*On call*\n<@U2>",
+		"*Until*\n<!date^1704700800^{date_short_pretty} {time}|2024-01-08 08:00 UTC>",
+		"*User group*\n<!subteam^G1|@oncall>",
+	}
+	if diff := cmp.Diff(wantFields, gotFields); diff != "" {
+		t.Errorf("fields mismatch (-want +got):\n%s", diff)
+	}
+}
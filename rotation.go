@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// staticRotation is an oncallProvider implementation computing the on-call
+// user locally from a rotation defined in the configuration. Every rotation
+// represents a single schedule.
+type staticRotation struct {
+	members []oncallUser
+	length  time.Duration
+	// start is the beginning of the first shift.
+	start time.Time
+}
+
+func newStaticRotation(cfg ConfigRotation) (*staticRotation, error) {
+	if len(cfg.Members) == 0 {
+		return nil, errors.New("rotation must have at least one member")
+	}
+	if cfg.Length <= 0 {
+		return nil, errors.New("rotation length must be positive")
+	}
+
+	loc := time.UTC
+	if cfg.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load time zone %q: %s", cfg.TimeZone, err)
+		}
+	}
+
+	handoffTime := cfg.HandoffTime
+	if handoffTime == "" {
+		handoffTime = "00:00"
+	}
+	start, err := time.ParseInLocation("2006-01-02 15:04", cfg.StartDate+" "+handoffTime, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start date %q and handoff time %q: %s", cfg.StartDate, handoffTime, err)
+	}
+
+	rot := &staticRotation{
+		length: cfg.Length,
+		start:  start,
+	}
+	for _, member := range cfg.Members {
+		user := oncallUser{id: member}
+		if strings.Contains(member, "@") {
+			user.email = member
+		} else {
+			user.name = member
+		}
+		rot.members = append(rot.members, user)
+	}
+
+	return rot, nil
+}
+
+// getSchedule returns a schedule for the rotation. Since rotations do not
+// have an identifier, one is derived from the configured name.
+func (rot *staticRotation) getSchedule(ctx context.Context, id, name string) (*oncallSchedule, error) {
+	return &oncallSchedule{
+		id:   "rotation:" + name,
+		name: name,
+	}, nil
+}
+
+func (rot *staticRotation) getOnCallUsers(ctx context.Context, schedule oncallSchedule) ([]oncallUser, error) {
+	fmt.Printf("Computing on-call users for rotation %s\n", schedule)
+	user, ok := rot.onCallUserAt(time.Now())
+	if !ok {
+		fmt.Printf("Rotation %s has not started yet\n", schedule)
+		return nil, nil
+	}
+	fmt.Printf("Got on-call user %s for rotation %s\n", user.id, schedule)
+
+	return []oncallUser{user}, nil
+}
+
+// onCallUserAt returns the member on call at the given time, or false if the
+// rotation has not started yet.
+func (rot *staticRotation) onCallUserAt(now time.Time) (oncallUser, bool) {
+	if now.Before(rot.start) {
+		return oncallUser{}, false
+	}
+
+	// Estimate the shift and correct it as needed since shifts spanning
+	// daylight saving time changes are not exactly as long as the rotation
+	// length.
+	shift := int(now.Sub(rot.start) / rot.length)
+	for rot.shiftStart(shift+1).Compare(now) <= 0 {
+		shift++
+	}
+	for shift > 0 && rot.shiftStart(shift).After(now) {
+		shift--
+	}
+
+	user := rot.members[shift%len(rot.members)]
+	user.until = rot.shiftStart(shift + 1)
+	return user, true
+}
+
+// shiftStart returns the start of the given shift. Rotation lengths of whole
+// days retain the handoff time across daylight saving time changes.
+func (rot *staticRotation) shiftStart(shift int) time.Time {
+	const day = 24 * time.Hour
+	if rot.length%day == 0 {
+		return rot.start.AddDate(0, 0, shift*int(rot.length/day))
+	}
+	return rot.start.Add(time.Duration(shift) * rot.length)
+}
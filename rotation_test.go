@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaticRotationOnCallUserAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %s", err)
+	}
+
+	tests := []struct {
+		name     string
+		inCfg    ConfigRotation
+		inNow    time.Time
+		wantUser string
+	}{
+		{
+			name: "before start",
+			inCfg: ConfigRotation{
+				Members:   []string{"jane@example.com", "John Doe"},
+				Length:    7 * 24 * time.Hour,
+				StartDate: "2024-01-01",
+			},
+			inNow: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "first shift",
+			inCfg: ConfigRotation{
+				Members:   []string{"jane@example.com", "John Doe"},
+				Length:    7 * 24 * time.Hour,
+				StartDate: "2024-01-01",
+			},
+			inNow:    time.Date(2024, 1, 7, 23, 59, 0, 0, time.UTC),
+			wantUser: "jane@example.com",
+		},
+		{
+			name: "second shift",
+			inCfg: ConfigRotation{
+				Members:   []string{"jane@example.com", "John Doe"},
+				Length:    7 * 24 * time.Hour,
+				StartDate: "2024-01-01",
+			},
+			inNow:    time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			wantUser: "John Doe",
+		},
+		{
+			name: "wraps around",
+			inCfg: ConfigRotation{
+				Members:   []string{"jane@example.com", "John Doe"},
+				Length:    7 * 24 * time.Hour,
+				StartDate: "2024-01-01",
+			},
+			inNow:    time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			wantUser: "jane@example.com",
+		},
+		{
+			name: "handoff time retained across daylight saving time change",
+			inCfg: ConfigRotation{
+				Members:     []string{"jane@example.com", "John Doe"},
+				Length:      7 * 24 * time.Hour,
+				HandoffTime: "09:00",
+				TimeZone:    "Europe/Berlin",
+				StartDate:   "2024-03-25",
+			},
+			// Second shift starts on April 1st at 09:00 CEST, i.e., 167 hours after the first one.
+			inNow:    time.Date(2024, 4, 1, 9, 0, 0, 0, berlin),
+			wantUser: "John Doe",
+		},
+		{
+			name: "sub-day rotation",
+			inCfg: ConfigRotation{
+				Members:     []string{"a@example.com", "b@example.com", "c@example.com"},
+				Length:      8 * time.Hour,
+				HandoffTime: "06:00",
+				StartDate:   "2024-01-01",
+			},
+			inNow:    time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC),
+			wantUser: "c@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rot, err := newStaticRotation(tt.inCfg)
+			if err != nil {
+				t.Fatalf("failed to create rotation: %s", err)
+			}
+
+			gotUser, ok := rot.onCallUserAt(tt.inNow)
+			if tt.wantUser == "" {
+				if ok {
+					t.Errorf("got on-call user %s, want none", gotUser.id)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("got no on-call user, want %s", tt.wantUser)
+			}
+			if gotUser.id != tt.wantUser {
+				t.Errorf("got on-call user %s, want %s", gotUser.id, tt.wantUser)
+			}
+		})
+	}
+}
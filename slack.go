@@ -1,35 +1,42 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/matryer/try"
 
-	"github.com/PagerDuty/go-pagerduty"
-
 	"github.com/slack-go/slack"
 )
 
 type slackUsers []slackUser
 
-func (users slackUsers) findByPDUser(pdUser pagerduty.User) *slackUser {
-	// check email match first since it's a distinctive identifier
-	for _, slackUser := range users {
-		if slackUser.email == pdUser.Email {
-			return &slackUser
+func (users slackUsers) findByOncallUser(user oncallUser) *slackUser {
+	// check email match first since it's a distinctive identifier. users
+	// without an email (e.g., name-only rotation members) would otherwise
+	// match Slack users without one, such as bots.
+	if user.email != "" {
+		for _, slackUser := range users {
+			if strings.EqualFold(slackUser.email, user.email) {
+				return &slackUser
+			}
 		}
 	}
 
 	// if we couldn't find an email match, use name. this is the second choice as name is not unique in an organization
 	for _, slackUser := range users {
-		if slackUser.realName == strings.ToLower(pdUser.Name) ||
-			slackUser.name == strings.ToLower(pdUser.Name) {
+		if slackUser.realName == strings.ToLower(user.name) ||
+			slackUser.name == strings.ToLower(user.name) {
 			return &slackUser
 		}
 	}
@@ -63,9 +70,17 @@ func (cl channelList) find(id, name string) *slack.Channel {
 type slackMetaClient struct {
 	slackClient  *slack.Client
 	channelTypes []string
+	// teamID scopes requests to a workspace of an Enterprise Grid
+	// organization. It is empty outside of Grid.
+	teamID string
+	// httpClient, apiURL, and token are used to call API methods that are
+	// not supported by slackClient.
+	httpClient *http.Client
+	apiURL     string
+	token      string
 }
 
-func newSlackMetaClient(token string, includePrivateChannels bool) *slackMetaClient {
+func newSlackMetaClient(token, teamID string, includePrivateChannels bool) *slackMetaClient {
 	channelTypes := []string{"public_channel"}
 	if includePrivateChannels {
 		channelTypes = append(channelTypes, "private_channel")
@@ -74,13 +89,30 @@ func newSlackMetaClient(token string, includePrivateChannels bool) *slackMetaCli
 	return &slackMetaClient{
 		slackClient:  slack.New(token),
 		channelTypes: channelTypes,
+		teamID:       teamID,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		apiURL:       slack.APIURL,
+		token:        token,
+	}
+}
+
+// userGroupsOptions returns the given options to list user groups with, scoped
+// to the team if one is configured.
+func (metaClient *slackMetaClient) userGroupsOptions(options ...slack.GetUserGroupsOption) []slack.GetUserGroupsOption {
+	if metaClient.teamID != "" {
+		options = append(options, slack.GetUserGroupsOptionWithTeamID(metaClient.teamID))
 	}
+	return options
 }
 
 func (metaClient *slackMetaClient) getSlackUsers(ctx context.Context) (slackUsers, error) {
 	// GetUsersContext retries on rate-limit errors, so no need to wrap it around
 	// retryOnSlackRateLimit.
-	apiUsers, err := metaClient.slackClient.GetUsersContext(ctx)
+	var options []slack.GetUsersOption
+	if metaClient.teamID != "" {
+		options = append(options, slack.GetUsersOptionTeamID(metaClient.teamID))
+	}
+	apiUsers, err := metaClient.slackClient.GetUsersContext(ctx, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +147,7 @@ func (metaClient *slackMetaClient) getChannels(ctx context.Context) (channelList
 				ExcludeArchived: true,
 				Limit:           200,
 				Types:           metaClient.channelTypes,
+				TeamID:          metaClient.teamID,
 			})
 			return err
 		})
@@ -145,7 +178,7 @@ func (metaClient *slackMetaClient) getUserGroups(ctx context.Context) ([]UserGro
 	var groups []slack.UserGroup
 	retErr := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
 		var err error
-		groups, err = metaClient.slackClient.GetUserGroupsContext(ctx, []slack.GetUserGroupsOption(nil)...)
+		groups, err = metaClient.slackClient.GetUserGroupsContext(ctx, metaClient.userGroupsOptions()...)
 		return err
 	})
 	if retErr != nil {
@@ -164,6 +197,72 @@ func (metaClient *slackMetaClient) getUserGroups(ctx context.Context) ([]UserGro
 	return userGroups, nil
 }
 
+// ensureUserGroup re-enables the given user group if it is disabled, or
+// creates it if it does not exist. It returns nil in dry-run mode.
+func (metaClient *slackMetaClient) ensureUserGroup(ctx context.Context, ug UserGroup, dryRun bool) (*UserGroup, error) {
+	var groups []slack.UserGroup
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		groups, err = metaClient.slackClient.GetUserGroupsContext(ctx, metaClient.userGroupsOptions(slack.GetUserGroupsOptionIncludeDisabled(true))...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %s", err)
+	}
+
+	var disabled *slack.UserGroup
+	for _, group := range groups {
+		if group.DateDelete != 0 && ((ug.ID != "" && group.ID == ug.ID) ||
+			(ug.Name != "" && group.Name == ug.Name) ||
+			(ug.Handle != "" && group.Handle == ug.Handle)) {
+			disabled = &group
+			break
+		}
+	}
+
+	var group slack.UserGroup
+	if disabled != nil {
+		fmt.Printf("Enabling disabled user group %s\n", ug)
+		if dryRun {
+			fmt.Println("[DRY RUN] Not enabling user group")
+			return nil, nil
+		}
+		err = retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+			var err error
+			group, err = metaClient.slackClient.EnableUserGroupContext(ctx, disabled.ID)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to enable user group: %s", err)
+		}
+	} else {
+		fmt.Printf("Creating user group %s\n", ug)
+		if dryRun {
+			fmt.Println("[DRY RUN] Not creating user group")
+			return nil, nil
+		}
+		err = retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+			var err error
+			group, err = metaClient.slackClient.CreateUserGroupContext(ctx, slack.UserGroup{
+				Name:        ug.Name,
+				Handle:      ug.Handle,
+				Description: ug.Description,
+				TeamID:      metaClient.teamID,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user group: %s", err)
+		}
+	}
+
+	return &UserGroup{
+		ID:     group.ID,
+		Name:   group.Name,
+		Handle: group.Handle,
+	}, nil
+}
+
 type oncallGroups []*oncallGroup
 
 func (ocgs *oncallGroups) getOrCreate(ug UserGroup) *oncallGroup {
@@ -174,8 +273,9 @@ func (ocgs *oncallGroups) getOrCreate(ug UserGroup) *oncallGroup {
 	}
 
 	ocg := &oncallGroup{
-		userGroupID:   ug.ID,
-		userGroupName: ug.Name,
+		userGroupID:    ug.ID,
+		userGroupName:  ug.Name,
+		descriptionLoc: time.UTC,
 	}
 	*ocgs = append(*ocgs, ocg)
 	return ocg
@@ -185,6 +285,31 @@ type oncallGroup struct {
 	userGroupID   string
 	userGroupName string
 	members       []string
+	// descriptionTmpl is the template for the user group description, if
+	// any.
+	descriptionTmpl *template.Template
+	// descriptionLoc is the location that the description template renders
+	// shift ends in.
+	descriptionLoc *time.Location
+	onCall         onCallSummary
+	// description is the rendered description, or nil if the description
+	// is not managed.
+	description *string
+}
+
+// renderDescription renders the description template of the group, if any.
+func (ocg *oncallGroup) renderDescription() error {
+	if ocg.descriptionTmpl == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := ocg.descriptionTmpl.Execute(&buf, ocg.onCall); err != nil {
+		return fmt.Errorf("failed to render description template of user group %q: %s", ocg.userGroupName, err)
+	}
+	description := buf.String()
+	ocg.description = &description
+	return nil
 }
 
 func (ocg *oncallGroup) ensureMember(m string) {
@@ -228,7 +353,30 @@ func (metaClient *slackMetaClient) joinChannel(ctx context.Context, channelID st
 }
 
 func (metaClient *slackMetaClient) updateOncallGroupMembers(ctx context.Context, oncallGroups oncallGroups, dryRun bool) error {
+	var currentDescriptions map[string]string
 	for _, group := range oncallGroups {
+		if group.description != nil {
+			var err error
+			currentDescriptions, err = metaClient.getUserGroupDescriptions(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get user group descriptions: %s", err)
+			}
+			break
+		}
+	}
+
+	for _, group := range oncallGroups {
+		if err := metaClient.updateOncallGroupDescription(ctx, group, currentDescriptions[group.userGroupID], dryRun); err != nil {
+			return err
+		}
+
+		// User groups cannot be emptied, so they keep their members while
+		// nobody is on call.
+		if len(group.members) == 0 {
+			fmt.Printf("No on-call users for user group %q, leaving members untouched\n", group.userGroupName)
+			continue
+		}
+
 		currentMembers, err := metaClient.slackClient.GetUserGroupMembersContext(ctx, group.userGroupID)
 		if err != nil {
 			return fmt.Errorf("failed to get user group members for %q: %s", group.userGroupName, err)
@@ -254,13 +402,106 @@ func (metaClient *slackMetaClient) updateOncallGroupMembers(ctx context.Context,
 	return nil
 }
 
-func (metaClient *slackMetaClient) updateTopic(ctx context.Context, channelID string, topic string, dryRun bool) error {
+func (metaClient *slackMetaClient) updateOncallGroupDescription(ctx context.Context, group *oncallGroup, currentDescription string, dryRun bool) error {
+	if group.description == nil {
+		return nil
+	}
+
+	description := *group.description
+	if currentDescription == description {
+		fmt.Printf("User group %q already has the right description\n", group.userGroupName)
+		return nil
+	}
+	fmt.Printf("Updating description of user group %q from %q to %q\n", group.userGroupName, currentDescription, description)
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating user group description")
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		_, err := metaClient.slackClient.UpdateUserGroupContext(ctx, group.userGroupID, slack.UpdateUserGroupsOptionDescription(&description))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update user group description for %q: %s", group.userGroupName, err)
+	}
+
+	return nil
+}
+
+// getUserGroupDescriptions returns the descriptions of all user groups keyed
+// by ID.
+func (metaClient *slackMetaClient) getUserGroupDescriptions(ctx context.Context) (map[string]string, error) {
+	var groups []slack.UserGroup
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		groups, err = metaClient.slackClient.GetUserGroupsContext(ctx, metaClient.userGroupsOptions()...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := make(map[string]string, len(groups))
+	for _, group := range groups {
+		descriptions[group.ID] = group.Description
+	}
+	return descriptions, nil
+}
+
+var (
+	slackMarkupRE = regexp.MustCompile(`<([^<>|]+)(?:\|([^<>]*))?>`)
+	// slackEntityReplacer reverses the escaping that Slack applies to
+	// control characters.
+	slackEntityReplacer = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+)
+
+// normalizeSlackText returns the canonical form of text that may have been
+// auto-formatted by Slack. Links are reduced to their label if the label
+// is the link itself (as for auto-formatted links), mentions are reduced to
+// their IDs, and escaped characters are unescaped. This makes text sent to
+// Slack comparable to what the API returns for it.
+func normalizeSlackText(text string) string {
+	text = slackMarkupRE.ReplaceAllStringFunc(text, func(markup string) string {
+		m := slackMarkupRE.FindStringSubmatch(markup)
+		target, label := m[1], m[2]
+		switch target[0] {
+		case '@', '#', '!':
+			// Mentions render with their current names, so the label does
+			// not matter.
+			return "<" + target + ">"
+		}
+		if label == "" {
+			return target
+		}
+		for _, prefix := range []string{"", "http://", "https://", "mailto:"} {
+			if strings.HasPrefix(target, prefix) && target[len(prefix):] == label {
+				return label
+			}
+		}
+		return markup
+	})
+	return slackEntityReplacer.Replace(text)
+}
+
+// updateTopic sets the topic of the given channel. If markers are given, only
+// the part of the current topic between the markers is replaced.
+func (metaClient *slackMetaClient) updateTopic(ctx context.Context, channelID string, topic string, markers *ConfigTopicMarkers, dryRun bool) error {
 	channel, err := metaClient.getChannelByID(ctx, channelID)
 	if err != nil {
 		return err
 	}
 
-	if channel.Topic.Value == topic {
+	if markers != nil {
+		var ok bool
+		topic, ok = replaceMarkedRegion(channel.Topic.Value, topic, *markers)
+		if !ok {
+			fmt.Printf("WARNING: Not updating topic since it does not contain the markers %q and %q\n", markers.Start, markers.End)
+			return nil
+		}
+	}
+
+	if normalizeSlackText(channel.Topic.Value) == normalizeSlackText(topic) {
 		fmt.Println("Topic already set correctly")
 	} else {
 		fmt.Printf("Updating topic from\n[BEGIN-OF-OLD]\n%s\n[END-OF-OLD]\nto:\n[BEGIN-OF-NEW]\n%s\n[END-OF-NEW]\n", channel.Topic.Value, topic)
@@ -278,6 +519,145 @@ func (metaClient *slackMetaClient) updateTopic(ctx context.Context, channelID st
 	return nil
 }
 
+// replaceMarkedRegion replaces the text between the first start marker and
+// the subsequent end marker, and returns whether both markers were found.
+func replaceMarkedRegion(text, replacement string, markers ConfigTopicMarkers) (string, bool) {
+	start := strings.Index(text, markers.Start)
+	if start < 0 {
+		return "", false
+	}
+	regionStart := start + len(markers.Start)
+	end := strings.Index(text[regionStart:], markers.End)
+	if end < 0 {
+		return "", false
+	}
+
+	return text[:regionStart] + replacement + text[regionStart+end:], true
+}
+
+func (metaClient *slackMetaClient) updatePurpose(ctx context.Context, channelID string, purpose string, dryRun bool) error {
+	channel, err := metaClient.getChannelByID(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if normalizeSlackText(channel.Purpose.Value) == normalizeSlackText(purpose) {
+		fmt.Println("Purpose already set correctly")
+	} else {
+		fmt.Printf("Updating purpose from\n[BEGIN-OF-OLD]\n%s\n[END-OF-OLD]\nto:\n[BEGIN-OF-NEW]\n%s\n[END-OF-NEW]\n", channel.Purpose.Value, purpose)
+		if dryRun {
+			fmt.Println("[DRY RUN] Not updating purpose")
+			return nil
+		}
+		_, err := metaClient.slackClient.SetPurposeOfConversationContext(ctx, channel.ID, purpose)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Purpose updated")
+	}
+
+	return nil
+}
+
+// updateBookmark ensures that a link bookmark with the given properties
+// exists in the channel. The bookmark is looked up by ID first and by link
+// second, and created if it cannot be found. The ID of the bookmark is
+// returned unless it was not created because of a dry run.
+func (metaClient *slackMetaClient) updateBookmark(ctx context.Context, channelID, bookmarkID, title, link, emoji string, dryRun bool) (string, error) {
+	var bookmarks []slack.Bookmark
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		bookmarks, err = metaClient.slackClient.ListBookmarksContext(ctx, channelID)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list bookmarks: %s", err)
+	}
+
+	var existing *slack.Bookmark
+	for _, bm := range bookmarks {
+		if bookmarkID != "" && bm.ID == bookmarkID {
+			existing = &bm
+			break
+		}
+	}
+	if existing == nil {
+		for _, bm := range bookmarks {
+			if bm.Link == link {
+				existing = &bm
+				break
+			}
+		}
+	}
+
+	if existing == nil {
+		fmt.Printf("Creating bookmark %q linking to %s\n", title, link)
+		if dryRun {
+			fmt.Println("[DRY RUN] Not creating bookmark")
+			return "", nil
+		}
+		var created slack.Bookmark
+		err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+			var err error
+			created, err = metaClient.slackClient.AddBookmarkContext(ctx, channelID, slack.AddBookmarkParameters{
+				Title: title,
+				Type:  "link",
+				Link:  link,
+				Emoji: emoji,
+			})
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create bookmark: %s", err)
+		}
+		fmt.Println("Bookmark created")
+		return created.ID, nil
+	}
+
+	if existing.Title == title && existing.Link == link && existing.Emoji == emoji {
+		fmt.Println("Bookmark already set correctly")
+		return existing.ID, nil
+	}
+
+	fmt.Printf("Updating bookmark from %q linking to %s to %q linking to %s\n", existing.Title, existing.Link, title, link)
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating bookmark")
+		return existing.ID, nil
+	}
+	err = retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		_, err := metaClient.slackClient.EditBookmarkContext(ctx, channelID, existing.ID, slack.EditBookmarkParameters{
+			Title: &title,
+			Link:  link,
+			Emoji: &emoji,
+		})
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update bookmark: %s", err)
+	}
+	fmt.Println("Bookmark updated")
+
+	return existing.ID, nil
+}
+
+func (metaClient *slackMetaClient) postMessage(ctx context.Context, channelID, text string, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not posting message to channel %s:\n%s\n", channelID, text)
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		_, _, err := metaClient.slackClient.PostMessageContext(ctx, channelID, slack.MsgOptionText(text, false))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Posted message to channel %s\n", channelID)
+
+	return nil
+}
+
 func createSlackUser(apiUser slack.User) slackUser {
 	return slackUser{
 		id:       apiUser.ID,
@@ -302,3 +682,267 @@ func retryOnSlackRateLimit(ctx context.Context, f func(ctx context.Context) erro
 		return false, err
 	})
 }
+
+// postBlocks posts a Block Kit message to the given channel and returns its
+// timestamp. The text is shown in notifications.
+func (metaClient *slackMetaClient) postBlocks(ctx context.Context, channelID, text string, blocks []slack.Block, dryRun bool) (string, error) {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not posting message %q to channel %s\n", text, channelID)
+		return "", nil
+	}
+
+	var ts string
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		_, ts, err = metaClient.slackClient.PostMessageContext(ctx, channelID, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Posted message %s to channel %s\n", ts, channelID)
+
+	return ts, nil
+}
+
+// updateBlocks replaces the content of the message identified by the given
+// timestamp.
+func (metaClient *slackMetaClient) updateBlocks(ctx context.Context, channelID, ts, text string, blocks []slack.Block, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not updating message %s in channel %s\n", ts, channelID)
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		_, _, _, err := metaClient.slackClient.UpdateMessageContext(ctx, channelID, ts, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated message %s in channel %s\n", ts, channelID)
+
+	return nil
+}
+
+// pinMessage pins the message identified by the given timestamp. Messages
+// that are pinned already are left alone.
+func (metaClient *slackMetaClient) pinMessage(ctx context.Context, channelID, ts string, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not pinning message %s in channel %s\n", ts, channelID)
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		return metaClient.slackClient.AddPinContext(ctx, channelID, slack.NewRefToMessage(channelID, ts))
+	})
+	if err != nil && !isSlackError(err, "already_pinned") {
+		return err
+	}
+	fmt.Printf("Pinned message %s in channel %s\n", ts, channelID)
+
+	return nil
+}
+
+// isSlackError returns whether err is a Slack API error with the given code.
+func isSlackError(err error, code string) bool {
+	var ser slack.SlackErrorResponse
+	return errors.As(err, &ser) && ser.Err == code
+}
+
+// getChannelMembers returns the IDs of the members of the given channel.
+func (metaClient *slackMetaClient) getChannelMembers(ctx context.Context, channelID string) ([]string, error) {
+	var members []string
+	params := &slack.GetUsersInConversationParameters{
+		ChannelID: channelID,
+		Limit:     1000,
+	}
+	for {
+		var (
+			page       []string
+			nextCursor string
+		)
+		err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+			var err error
+			page, nextCursor, err = metaClient.slackClient.GetUsersInConversationContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		if nextCursor == "" {
+			return members, nil
+		}
+		params.Cursor = nextCursor
+	}
+}
+
+func (metaClient *slackMetaClient) inviteToChannel(ctx context.Context, channelID string, userIDs []string, dryRun bool) error {
+	concatUsers := strings.Join(userIDs, ",")
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not inviting user(s) %s to channel %s\n", concatUsers, channelID)
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		_, err := metaClient.slackClient.InviteUsersToConversationContext(ctx, channelID, userIDs...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Invited user(s) %s to channel %s\n", concatUsers, channelID)
+
+	return nil
+}
+
+func (metaClient *slackMetaClient) removeFromChannel(ctx context.Context, channelID, userID string, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not removing user %s from channel %s\n", userID, channelID)
+		return nil
+	}
+
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		return metaClient.slackClient.KickUserFromConversationContext(ctx, channelID, userID)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed user %s from channel %s\n", userID, channelID)
+
+	return nil
+}
+
+// callAPI calls the given Slack API method with params encoded as JSON body
+// and decodes the response into out (unless nil). Slack API errors are
+// returned as slack.SlackErrorResponse, and rate limits as
+// *slack.RateLimitedError.
+func (metaClient *slackMetaClient) callAPI(ctx context.Context, method string, params, out interface{}) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+metaClient.token)
+
+	var body json.RawMessage
+	err := doJSONRequest(ctx, metaClient.httpClient, http.MethodPost, metaClient.apiURL+method, header, params, &body)
+	if err != nil {
+		var se *httpStatusError
+		if errors.As(err, &se) && se.statusCode == http.StatusTooManyRequests {
+			retryAfter := se.retryAfter
+			if retryAfter == 0 {
+				retryAfter = 1 * time.Minute
+			}
+			return &slack.RateLimitedError{RetryAfter: retryAfter}
+		}
+		return err
+	}
+
+	var resp slack.SlackResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode response: %s", err)
+	}
+	if !resp.Ok {
+		return slack.SlackErrorResponse{Err: resp.Error, ResponseMetadata: resp.ResponseMetadata}
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to decode response: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// canvasDocumentContent is the content of a Slack canvas.
+type canvasDocumentContent struct {
+	Type     string `json:"type"`
+	Markdown string `json:"markdown"`
+}
+
+func markdownCanvasContent(markdown string) canvasDocumentContent {
+	return canvasDocumentContent{
+		Type:     "markdown",
+		Markdown: markdown,
+	}
+}
+
+// getChannelCanvasID returns the ID of the canvas of the given channel, or an
+// empty string if the channel has none.
+func (metaClient *slackMetaClient) getChannelCanvasID(ctx context.Context, channelID string) (string, error) {
+	var channel *slack.Channel
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		channel, err = metaClient.getChannelByID(ctx, channelID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if channel.Properties == nil {
+		return "", nil
+	}
+
+	return channel.Properties.Canvas.FileId, nil
+}
+
+// createChannelCanvas creates the canvas of the given channel from Markdown
+// and returns its ID.
+func (metaClient *slackMetaClient) createChannelCanvas(ctx context.Context, channelID, markdown string, dryRun bool) (string, error) {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not creating canvas in channel %s\n", channelID)
+		return "", nil
+	}
+
+	params := struct {
+		ChannelID       string                `json:"channel_id"`
+		DocumentContent canvasDocumentContent `json:"document_content"`
+	}{
+		ChannelID:       channelID,
+		DocumentContent: markdownCanvasContent(markdown),
+	}
+	var resp struct {
+		CanvasID string `json:"canvas_id"`
+	}
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		return metaClient.callAPI(ctx, "conversations.canvases.create", params, &resp)
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Created canvas %s in channel %s\n", resp.CanvasID, channelID)
+
+	return resp.CanvasID, nil
+}
+
+// editCanvas replaces the content of the given canvas with Markdown.
+func (metaClient *slackMetaClient) editCanvas(ctx context.Context, canvasID, markdown string, dryRun bool) error {
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not updating canvas %s\n", canvasID)
+		return nil
+	}
+
+	type canvasChange struct {
+		Operation       string                `json:"operation"`
+		DocumentContent canvasDocumentContent `json:"document_content"`
+	}
+	params := struct {
+		CanvasID string         `json:"canvas_id"`
+		Changes  []canvasChange `json:"changes"`
+	}{
+		CanvasID: canvasID,
+		Changes: []canvasChange{
+			{
+				Operation:       "replace",
+				DocumentContent: markdownCanvasContent(markdown),
+			},
+		},
+	}
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		return metaClient.callAPI(ctx, "canvases.edit", params, nil)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Updated canvas %s\n", canvasID)
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+// fakeSlackUserGroups is a minimal stand-in for the Slack user groups API.
+type fakeSlackUserGroups struct {
+	groups   []slack.UserGroup
+	requests []string
+}
+
+func (fsu *fakeSlackUserGroups) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		fsu.requests = append(fsu.requests, r.URL.Path)
+
+		switch r.URL.Path {
+		case "/usergroups.list":
+			groups := []slack.UserGroup{}
+			for _, group := range fsu.groups {
+				if group.DateDelete == 0 || r.Form.Get("include_disabled") == "true" {
+					groups = append(groups, group)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "usergroups": groups})
+		case "/usergroups.update":
+			for i, group := range fsu.groups {
+				if group.ID == r.PostForm.Get("usergroup") {
+					fsu.groups[i].Description = r.PostForm.Get("description")
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "usergroup": fsu.groups[i]})
+					return
+				}
+			}
+			fmt.Fprint(w, `{"ok": false, "error": "no_such_subteam"}`)
+		case "/usergroups.users.list":
+			for _, group := range fsu.groups {
+				if group.ID == r.Form.Get("usergroup") {
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "users": group.Users})
+					return
+				}
+			}
+			fmt.Fprint(w, `{"ok": false, "error": "no_such_subteam"}`)
+		case "/usergroups.users.update":
+			for i, group := range fsu.groups {
+				if group.ID == r.PostForm.Get("usergroup") {
+					fsu.groups[i].Users = strings.Split(r.PostForm.Get("users"), ",")
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "usergroup": fsu.groups[i]})
+					return
+				}
+			}
+			fmt.Fprint(w, `{"ok": false, "error": "no_such_subteam"}`)
+		case "/usergroups.enable":
+			for i, group := range fsu.groups {
+				if group.ID == r.PostForm.Get("usergroup") {
+					fsu.groups[i].DateDelete = 0
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "usergroup": fsu.groups[i]})
+					return
+				}
+			}
+			fmt.Fprint(w, `{"ok": false, "error": "no_such_subteam"}`)
+		case "/usergroups.create":
+			group := slack.UserGroup{
+				ID:          fmt.Sprintf("G%d", len(fsu.groups)+1),
+				Name:        r.PostForm.Get("name"),
+				Handle:      r.PostForm.Get("handle"),
+				Description: r.PostForm.Get("description"),
+			}
+			fsu.groups = append(fsu.groups, group)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "usergroup": group})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestAssignUserGroups(t *testing.T) {
+	fsu := &fakeSlackUserGroups{
+		groups: []slack.UserGroup{
+			{ID: "G1", Name: "Existing", Handle: "existing"},
+			{ID: "G2", Name: "Disabled", Handle: "disabled", DateDelete: 1700000000},
+		},
+	}
+	srv := httptest.NewServer(fsu.handler(t))
+	defer srv.Close()
+
+	conn := &slackConnection{
+		client:     &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		userGroups: UserGroups{{ID: "G1", Name: "Existing", Handle: "existing"}},
+	}
+
+	var primary oncallSchedule
+	err := conn.assignUserGroups(context.Background(), "team", &primary, UserGroups{
+		{Handle: "existing"},
+		{Name: "Disabled", Handle: "disabled", Create: true},
+		{Name: "New", Handle: "new", Description: "On-call engineers", Create: true},
+	}, false)
+	if err != nil {
+		t.Fatalf("failed to assign user groups: %s", err)
+	}
+
+	// The created user group must be reused.
+	var secondary oncallSchedule
+	err = conn.assignUserGroups(context.Background(), "team", &secondary, UserGroups{
+		{Name: "New", Handle: "new", Create: true},
+	}, false)
+	if err != nil {
+		t.Fatalf("failed to assign user groups: %s", err)
+	}
+
+	wantPrimary := UserGroups{
+		{ID: "G1", Name: "Existing", Handle: "existing"},
+		{ID: "G2", Name: "Disabled", Handle: "disabled"},
+		{ID: "G3", Name: "New", Handle: "new"},
+	}
+	if diff := cmp.Diff(wantPrimary, primary.userGroups); diff != "" {
+		t.Errorf("primary user groups mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(UserGroups{{ID: "G3", Name: "New", Handle: "new"}}, secondary.userGroups); diff != "" {
+		t.Errorf("secondary user groups mismatch (-want +got):\n%s", diff)
+	}
+
+	wantRequests := []string{"/usergroups.list", "/usergroups.enable", "/usergroups.list", "/usergroups.create"}
+	if diff := cmp.Diff(wantRequests, fsu.requests); diff != "" {
+		t.Errorf("requests mismatch (-want +got):\n%s", diff)
+	}
+	if got := fsu.groups[2].Description; got != "On-call engineers" {
+		t.Errorf("got description %q, want %q", got, "On-call engineers")
+	}
+
+	var tertiary oncallSchedule
+	err = conn.assignUserGroups(context.Background(), "team", &tertiary, UserGroups{{Handle: "missing"}}, false)
+	if err == nil {
+		t.Error("got no error for missing user group, want one")
+	}
+}
+
+func TestUpdateOncallGroupMembersDescription(t *testing.T) {
+	fsu := &fakeSlackUserGroups{
+		groups: []slack.UserGroup{
+			{ID: "G1", Name: "Primary", Handle: "primary", Description: "On call", Users: []string{"U1"}},
+			{ID: "G2", Name: "Secondary", Handle: "secondary", Description: "Unmanaged", Users: []string{"U2"}},
+		},
+	}
+	srv := httptest.NewServer(fsu.handler(t))
+	defer srv.Close()
+	slClient := &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))}
+
+	tmpl := template.Must(template.New("description").Parse(`Currently: {{.}}, until {{.Until.Weekday}}`))
+	until := time.Date(2024, 1, 12, 9, 0, 0, 0, time.UTC)
+	newGroups := func() oncallGroups {
+		primary := &oncallGroup{userGroupID: "G1", userGroupName: "Primary", members: []string{"U1"}, descriptionTmpl: tmpl}
+		primary.onCall.add([]oncallUser{{name: "Jane Doe", until: until}}, time.UTC)
+		secondary := &oncallGroup{userGroupID: "G2", userGroupName: "Secondary", members: []string{"U2"}}
+		ocgs := oncallGroups{primary, secondary}
+		for _, ocg := range ocgs {
+			if err := ocg.renderDescription(); err != nil {
+				t.Fatalf("failed to render description: %s", err)
+			}
+		}
+		return ocgs
+	}
+
+	for i := 0; i < 2; i++ {
+		fsu.requests = nil
+		if err := slClient.updateOncallGroupMembers(context.Background(), newGroups(), false); err != nil {
+			t.Fatalf("run %d: failed to update on-call groups: %s", i, err)
+		}
+		wantRequests := []string{"/usergroups.list", "/usergroups.users.list", "/usergroups.users.list"}
+		if i == 0 {
+			wantRequests = []string{"/usergroups.list", "/usergroups.update", "/usergroups.users.list", "/usergroups.users.list"}
+		}
+		if diff := cmp.Diff(wantRequests, fsu.requests); diff != "" {
+			t.Errorf("run %d: requests mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+
+	gotDescriptions := []string{fsu.groups[0].Description, fsu.groups[1].Description}
+	if diff := cmp.Diff([]string{"Currently: Jane Doe, until Friday", "Unmanaged"}, gotDescriptions); diff != "" {
+		t.Errorf("descriptions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFindByOncallUser(t *testing.T) {
+	users := slackUsers{
+		{id: "USLACKBOT", name: "slackbot"},
+		{id: "U1", name: "jane", realName: "jane doe", email: "jane@example.com"},
+		{id: "U2", name: "john", realName: "john doe", email: "john@example.com"},
+	}
+
+	tests := []struct {
+		name   string
+		user   oncallUser
+		wantID string
+	}{
+		{
+			name:   "email match",
+			user:   oncallUser{name: "Someone", email: "jane@example.com"},
+			wantID: "U1",
+		},
+		{
+			name:   "case-insensitive email match",
+			user:   oncallUser{email: "John@Example.com"},
+			wantID: "U2",
+		},
+		{
+			name:   "name match without email",
+			user:   oncallUser{name: "John Doe"},
+			wantID: "U2",
+		},
+		{
+			name: "no match without email",
+			user: oncallUser{name: "Max Mustermann"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID string
+			if slUser := users.findByOncallUser(tt.user); slUser != nil {
+				gotID = slUser.id
+			}
+			if gotID != tt.wantID {
+				t.Errorf("got Slack user %q, want %q", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestNormalizeSlackText(t *testing.T) {
+	tests := []struct {
+		name      string
+		sent      string
+		readBack  string
+		wantEqual bool
+	}{
+		{
+			name:      "auto-linked domain",
+			sent:      "go to example.com for help",
+			readBack:  "go to <http://example.com|example.com> for help",
+			wantEqual: true,
+		},
+		{
+			name:      "auto-linked URL",
+			sent:      "runbook: https://example.com/runbook?a=1&b=2",
+			readBack:  "runbook: <https://example.com/runbook?a=1&amp;b=2>",
+			wantEqual: true,
+		},
+		{
+			name:      "hand-formatted URL",
+			sent:      "runbook: <https://example.com/runbook>",
+			readBack:  "runbook: <https://example.com/runbook>",
+			wantEqual: true,
+		},
+		{
+			name:      "auto-linked email address",
+			sent:      "mail oncall@example.com",
+			readBack:  "mail <mailto:oncall@example.com|oncall@example.com>",
+			wantEqual: true,
+		},
+		{
+			name:      "escaped characters",
+			sent:      "primary & secondary <3",
+			readBack:  "primary &amp; secondary &lt;3",
+			wantEqual: true,
+		},
+		{
+			name:      "labeled mentions",
+			sent:      "on call: <@U1> (<!subteam^G1>) in <#C1>",
+			readBack:  "on call: <@U1|jane> (<!subteam^G1|@oncall>) in <#C1|awesome>",
+			wantEqual: true,
+		},
+		{
+			name:      "different mention",
+			sent:      "on call: <@U1>",
+			readBack:  "on call: <@U2>",
+			wantEqual: false,
+		},
+		{
+			name:      "labeled link",
+			sent:      "<https://example.com|docs>",
+			readBack:  "<https://example.com|documentation>",
+			wantEqual: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSent, gotReadBack := normalizeSlackText(tt.sent), normalizeSlackText(tt.readBack)
+			if gotEqual := gotSent == gotReadBack; gotEqual != tt.wantEqual {
+				t.Errorf("got equal %t (%q vs. %q), want %t", gotEqual, gotSent, gotReadBack, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestUpdateTopicWithMarkers(t *testing.T) {
+	markers := &ConfigTopicMarkers{Start: "[on-call:", End: "]"}
+
+	tests := []struct {
+		name      string
+		curTopic  string
+		topic     string
+		wantTopic string
+	}{
+		{
+			name:      "replace marked region",
+			curTopic:  "Welcome to <#C1|awesome> [on-call: <@U1>] see <https://example.com/runbook>",
+			topic:     " <@U2>",
+			wantTopic: "Welcome to <#C1|awesome> [on-call: <@U2>] see <https://example.com/runbook>",
+		},
+		{
+			name:     "marked region up-to-date",
+			curTopic: "Welcome [on-call: <@U1>] &amp; goodbye",
+			topic:    " <@U1>",
+		},
+		{
+			name:     "missing end marker",
+			curTopic: "Welcome [on-call: <@U1>",
+			topic:    " <@U2>",
+		},
+		{
+			name:     "missing markers",
+			curTopic: "Welcome",
+			topic:    " <@U2>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTopic string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("failed to parse form: %s", err)
+					return
+				}
+				switch r.URL.Path {
+				case "/conversations.info":
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{
+						"ok":      true,
+						"channel": map[string]interface{}{"id": "C1", "topic": map[string]string{"value": tt.curTopic}},
+					})
+				case "/conversations.setTopic":
+					gotTopic = r.PostForm.Get("topic")
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "channel": map[string]string{"id": "C1"}})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			slClient := &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))}
+			if err := slClient.updateTopic(context.Background(), "C1", tt.topic, markers, false); err != nil {
+				t.Fatalf("failed to update topic: %s", err)
+			}
+			if gotTopic != tt.wantTopic {
+				t.Errorf("got topic %q, want %q", gotTopic, tt.wantTopic)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistedState is the JSON document stored in the state file. It allows
+// pdsync to remember what it did across restarts.
+type persistedState struct {
+	// OnCalls holds the on-calls last delivered to each handoff target, keyed
+	// by sync name and target.
+	OnCalls map[string][]persistedOnCall `json:"onCalls"`
+	// HandoffEmails holds the schedule IDs and recipients of the handoff
+	// emails sent for a handoff that was not fully delivered yet, keyed by
+	// sync name.
+	HandoffEmails map[string][]string `json:"handoffEmails"`
+	// HandoffAnnouncements holds the IDs of the schedules whose announcement
+	// was posted for a handoff that was not fully announced yet, keyed by sync
+	// name.
+	HandoffAnnouncements map[string][]string `json:"handoffAnnouncements"`
+	// Bookmarks holds the IDs of the Slack bookmarks managed by pdsync, keyed
+	// by sync name.
+	Bookmarks map[string]string `json:"bookmarks"`
+	// Statuses holds the Slack statuses set by pdsync by Slack user ID, keyed
+	// by sync name.
+	Statuses map[string]map[string]setSlackStatus `json:"statuses"`
+	// Reminders holds the start times of the shifts reminded of, keyed by
+	// schedule ID, user ID, and start time.
+	Reminders map[string]time.Time `json:"reminders"`
+	// Rosters holds the roster messages managed by pdsync, keyed by sync name.
+	Rosters map[string]rosterMessage `json:"rosters"`
+	// ChannelMembers holds the IDs of the users that pdsync invited by
+	// channel ID, keyed by sync name.
+	ChannelMembers map[string]map[string][]string `json:"channelMembers"`
+	// Canvases holds the channel canvases managed by pdsync, keyed by sync
+	// name.
+	Canvases map[string]canvasDocument `json:"canvases"`
+}
+
+type persistedOnCall struct {
+	ScheduleID   string          `json:"scheduleID"`
+	ScheduleName string          `json:"scheduleName"`
+	Users        []persistedUser `json:"users"`
+}
+
+type persistedUser struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func newPersistedState() *persistedState {
+	return &persistedState{
+		OnCalls:              map[string][]persistedOnCall{},
+		HandoffEmails:        map[string][]string{},
+		HandoffAnnouncements: map[string][]string{},
+		Bookmarks:            map[string]string{},
+		Statuses:             map[string]map[string]setSlackStatus{},
+		Reminders:            map[string]time.Time{},
+		Rosters:              map[string]rosterMessage{},
+		ChannelMembers:       map[string]map[string][]string{},
+		Canvases:             map[string]canvasDocument{},
+	}
+}
+
+// stateStore reads and writes the persisted state from and to a file.
+type stateStore struct {
+	path string
+}
+
+func newStateStore(path string) *stateStore {
+	return &stateStore{path: path}
+}
+
+// load returns the persisted state, or an empty state if the file does not
+// exist yet.
+func (st *stateStore) load() (*persistedState, error) {
+	state := newPersistedState()
+	b, err := os.ReadFile(st.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %s", st.path, err)
+	}
+	if state.OnCalls == nil {
+		state.OnCalls = map[string][]persistedOnCall{}
+	}
+	if state.HandoffEmails == nil {
+		state.HandoffEmails = map[string][]string{}
+	}
+	if state.HandoffAnnouncements == nil {
+		state.HandoffAnnouncements = map[string][]string{}
+	}
+	if state.Bookmarks == nil {
+		state.Bookmarks = map[string]string{}
+	}
+	if state.Statuses == nil {
+		state.Statuses = map[string]map[string]setSlackStatus{}
+	}
+	if state.Reminders == nil {
+		state.Reminders = map[string]time.Time{}
+	}
+	if state.Rosters == nil {
+		state.Rosters = map[string]rosterMessage{}
+	}
+	if state.ChannelMembers == nil {
+		state.ChannelMembers = map[string]map[string][]string{}
+	}
+	if state.Canvases == nil {
+		state.Canvases = map[string]canvasDocument{}
+	}
+	return state, nil
+}
+
+// save writes the state atomically by replacing the file.
+func (st *stateStore) save(state *persistedState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %s", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(st.path), filepath.Base(st.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(b); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), st.path)
+}
+
+func persistOnCalls(onCalls []scheduleOnCall) []persistedOnCall {
+	pOnCalls := make([]persistedOnCall, 0, len(onCalls))
+	for _, onCall := range onCalls {
+		pOnCall := persistedOnCall{
+			ScheduleID:   onCall.schedule.id,
+			ScheduleName: onCall.schedule.name,
+			Users:        make([]persistedUser, 0, len(onCall.users)),
+		}
+		for _, user := range onCall.users {
+			pOnCall.Users = append(pOnCall.Users, persistedUser{
+				ID:    user.id,
+				Name:  user.name,
+				Email: user.email,
+			})
+		}
+		pOnCalls = append(pOnCalls, pOnCall)
+	}
+	return pOnCalls
+}
+
+func restoreOnCalls(pOnCalls []persistedOnCall) []scheduleOnCall {
+	onCalls := make([]scheduleOnCall, 0, len(pOnCalls))
+	for _, pOnCall := range pOnCalls {
+		onCall := scheduleOnCall{
+			schedule: oncallSchedule{
+				id:   pOnCall.ScheduleID,
+				name: pOnCall.ScheduleName,
+			},
+		}
+		for _, pUser := range pOnCall.Users {
+			onCall.users = append(onCall.users, oncallUser{
+				id:    pUser.ID,
+				name:  pUser.Name,
+				email: pUser.Email,
+			})
+		}
+		onCalls = append(onCalls, onCall)
+	}
+	return onCalls
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	st := newStateStore(filepath.Join(t.TempDir(), "state.json"))
+
+	s := newSyncer(syncerParams{stateStore: st})
+	if err := s.restoreState(); err != nil {
+		t.Fatalf("failed to restore missing state: %s", err)
+	}
+	if len(s.previousOnCalls) != 0 {
+		t.Fatalf("got previous on-calls %v from missing state, want none", s.previousOnCalls)
+	}
+
+	s.previousOnCalls["team"] = []scheduleOnCall{
+		{
+			schedule: oncallSchedule{id: "S1", name: "Primary"},
+			users:    []oncallUser{{id: "PD1", name: "Jane Doe", email: "jane@example.com"}},
+		},
+	}
+	s.handoffEmails["team"] = []string{"S1/jane@example.com"}
+	if err := s.persistState(); err != nil {
+		t.Fatalf("failed to persist state: %s", err)
+	}
+
+	restored := newSyncer(syncerParams{stateStore: st})
+	if err := restored.restoreState(); err != nil {
+		t.Fatalf("failed to restore state: %s", err)
+	}
+	if diff := cmp.Diff(s.previousOnCalls, restored.previousOnCalls, cmp.AllowUnexported(scheduleOnCall{}, oncallSchedule{}, oncallUser{})); diff != "" {
+		t.Errorf("restored on-calls mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(s.handoffEmails, restored.handoffEmails); diff != "" {
+		t.Errorf("restored handoff emails mismatch (-want +got):\n%s", diff)
+	}
+}
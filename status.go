@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const defaultSlackStatusEmoji = ":pager:"
+
+// slackStatus is a Slack profile status.
+type slackStatus struct {
+	Text  string `json:"text"`
+	Emoji string `json:"emoji"`
+}
+
+func (st slackStatus) isEmpty() bool {
+	return st.Text == "" && st.Emoji == ""
+}
+
+// setSlackStatus is a Slack status set by pdsync along with its expiration,
+// which is zero if the status does not expire.
+type setSlackStatus struct {
+	slackStatus
+	Expiration time.Time `json:"expiration"`
+}
+
+func newSlackStatus(cfgStatus ConfigStatus, scheduleName string) *slackStatus {
+	status := &slackStatus{
+		Text:  cfgStatus.Text,
+		Emoji: cfgStatus.Emoji,
+	}
+	if status.Text == "" {
+		status.Text = "On call for " + scheduleName
+	}
+	if status.Emoji == "" {
+		status.Emoji = defaultSlackStatusEmoji
+	}
+	return status
+}
+
+// syncStatuses sets the Slack status of on-call users on schedules that have
+// a status configured, and clears the statuses pdsync set for users no longer
+// on call. Statuses that pdsync did not set are never changed. If the end of
+// the shift is known, the status expires by then in case pdsync does not get
+// to clear it, and it is renewed if the user remains on call beyond that.
+func (s *syncer) syncStatuses(ctx context.Context, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	wantStatuses := map[string]slackStatus{}
+	expirations := map[string]time.Time{}
+	for _, onCall := range onCalls {
+		if onCall.schedule.slackStatus == nil {
+			continue
+		}
+		for _, onCallUser := range onCall.users {
+			slUser := slackSync.slack.users.findByOncallUser(onCallUser)
+			if slUser == nil {
+				return fmt.Errorf("failed to find Slack user for on-call user %s", onCallUser)
+			}
+			// The first schedule wins for users on call for multiple ones.
+			if _, ok := wantStatuses[slUser.id]; !ok {
+				wantStatuses[slUser.id] = *onCall.schedule.slackStatus
+				expirations[slUser.id] = onCallUser.until
+			}
+		}
+	}
+
+	setStatuses := s.statuses[slackSync.name]
+	if setStatuses == nil {
+		setStatuses = map[string]setSlackStatus{}
+		s.statuses[slackSync.name] = setStatuses
+	}
+
+	for userID, wantStatus := range wantStatuses {
+		if setStatus, ok := setStatuses[userID]; ok {
+			if setStatus.Expiration.Equal(expirations[userID]) || s.isStatusSetByOtherSync(slackSync.name, userID) {
+				continue
+			}
+			if err := s.renewStatus(ctx, slackSync, userID, setStatus, wantStatus, expirations[userID]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		curStatus, err := slackSync.slack.statusClient.getStatus(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get status of user %s: %s", userID, err)
+		}
+		if s.isStatusSetByOtherSync(slackSync.name, userID) {
+			// Adopt the status so that it gets cleared by whichever sync
+			// stops managing it last.
+			fmt.Printf("Status of user %s is already managed by another sync\n", userID)
+			if !slackSync.dryRun {
+				setStatuses[userID] = setSlackStatus{slackStatus: curStatus}
+			}
+			continue
+		}
+		if !curStatus.isEmpty() {
+			fmt.Printf("Not overwriting status %q of user %s that was not set by pdsync\n", curStatus.Text, userID)
+			continue
+		}
+
+		if err := slackSync.slack.statusClient.setStatus(ctx, userID, wantStatus, expirations[userID], slackSync.dryRun); err != nil {
+			return fmt.Errorf("failed to set status of user %s: %s", userID, err)
+		}
+		if !slackSync.dryRun {
+			setStatuses[userID] = setSlackStatus{slackStatus: wantStatus, Expiration: expirations[userID]}
+		}
+	}
+
+	for userID, setStatus := range setStatuses {
+		if _, ok := wantStatuses[userID]; ok {
+			continue
+		}
+
+		if s.isStatusSetByOtherSync(slackSync.name, userID) {
+			fmt.Printf("Not clearing status of user %s since another sync manages it\n", userID)
+		} else {
+			curStatus, err := slackSync.slack.statusClient.getStatus(ctx, userID)
+			if err != nil {
+				return fmt.Errorf("failed to get status of user %s: %s", userID, err)
+			}
+			if curStatus != setStatus.slackStatus {
+				fmt.Printf("Not clearing status %q of user %s that was changed since pdsync set it\n", curStatus.Text, userID)
+			} else {
+				if err := slackSync.slack.statusClient.setStatus(ctx, userID, slackStatus{}, time.Time{}, slackSync.dryRun); err != nil {
+					return fmt.Errorf("failed to clear status of user %s: %s", userID, err)
+				}
+				if slackSync.dryRun {
+					continue
+				}
+			}
+		}
+		delete(setStatuses, userID)
+	}
+
+	return nil
+}
+
+// renewStatus moves the expiration of a status set by pdsync for a user who is
+// still on call. The status is set again if it expired already, but left alone
+// if it was changed in the meantime.
+func (s *syncer) renewStatus(ctx context.Context, slackSync runSlackSync, userID string, setStatus setSlackStatus, wantStatus slackStatus, expiration time.Time) error {
+	curStatus, err := slackSync.slack.statusClient.getStatus(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get status of user %s: %s", userID, err)
+	}
+	if !curStatus.isEmpty() && curStatus != setStatus.slackStatus {
+		fmt.Printf("Not renewing status %q of user %s that was changed since pdsync set it\n", curStatus.Text, userID)
+		if !slackSync.dryRun {
+			setStatus.Expiration = expiration
+			s.statuses[slackSync.name][userID] = setStatus
+		}
+		return nil
+	}
+
+	if err := slackSync.slack.statusClient.setStatus(ctx, userID, wantStatus, expiration, slackSync.dryRun); err != nil {
+		return fmt.Errorf("failed to renew status of user %s: %s", userID, err)
+	}
+	if !slackSync.dryRun {
+		s.statuses[slackSync.name][userID] = setSlackStatus{slackStatus: wantStatus, Expiration: expiration}
+	}
+	return nil
+}
+
+func (s *syncer) isStatusSetByOtherSync(syncName, userID string) bool {
+	for otherSyncName, setStatuses := range s.statuses {
+		if otherSyncName == syncName {
+			continue
+		}
+		if _, ok := setStatuses[userID]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// slackStatusClient manages Slack profile statuses, which requires a user
+// token.
+type slackStatusClient struct {
+	slackClient *slack.Client
+}
+
+func newSlackStatusClient(userToken string) *slackStatusClient {
+	return &slackStatusClient{
+		slackClient: slack.New(userToken),
+	}
+}
+
+func (cl *slackStatusClient) getStatus(ctx context.Context, userID string) (slackStatus, error) {
+	var profile *slack.UserProfile
+	err := retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		var err error
+		profile, err = cl.slackClient.GetUserProfileContext(ctx, &slack.GetUserProfileParameters{UserID: userID})
+		return err
+	})
+	if err != nil {
+		return slackStatus{}, err
+	}
+
+	return slackStatus{
+		Text:  profile.StatusText,
+		Emoji: profile.StatusEmoji,
+	}, nil
+}
+
+// setStatus sets the status of the given user. An empty status clears it. A
+// zero expiration keeps the status until it is changed.
+func (cl *slackStatusClient) setStatus(ctx context.Context, userID string, status slackStatus, expiration time.Time, dryRun bool) error {
+	var expirationUnix int64
+	switch {
+	case status.isEmpty():
+		fmt.Printf("Clearing status of user %s\n", userID)
+	case expiration.IsZero():
+		fmt.Printf("Setting status of user %s to %s %q\n", userID, status.Emoji, status.Text)
+	default:
+		expirationUnix = expiration.Unix()
+		fmt.Printf("Setting status of user %s to %s %q until %s\n", userID, status.Emoji, status.Text, expiration)
+	}
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating status")
+		return nil
+	}
+
+	return retryOnSlackRateLimit(ctx, func(ctx context.Context) error {
+		return cl.slackClient.SetUserCustomStatusContextWithUser(ctx, userID, status.Text, status.Emoji, expirationUnix)
+	})
+}
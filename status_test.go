@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+func TestSyncStatuses(t *testing.T) {
+	statuses := map[string]slackStatus{
+		"U2": {Text: "In a meeting", Emoji: ":calendar:"},
+	}
+	expirations := map[string]int{}
+	srv := newStatusTestServer(t, statuses, expirations)
+	defer srv.Close()
+
+	conn := &slackConnection{
+		statusClient: &slackStatusClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users: slackUsers{
+			{id: "U1", email: "jane@example.com"},
+			{id: "U2", email: "john@example.com"},
+		},
+	}
+	s := newSyncer(syncerParams{})
+	slackSync := runSlackSync{name: "team", slack: conn}
+	schedule := oncallSchedule{id: "S1", name: "Primary", slackStatus: newSlackStatus(ConfigStatus{}, "Primary")}
+	until := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	jane := oncallUser{id: "PD1", email: "jane@example.com", until: until}
+	john := oncallUser{id: "PD2", email: "john@example.com"}
+	onCallStatus := slackStatus{Text: "On call for Primary", Emoji: ":pager:"}
+
+	steps := []struct {
+		name         string
+		users        []oncallUser
+		humanChanges map[string]slackStatus
+		wantStatuses map[string]slackStatus
+	}{
+		{
+			name:  "set status unless set by human",
+			users: []oncallUser{jane, john},
+			wantStatuses: map[string]slackStatus{
+				"U1": onCallStatus,
+				"U2": {Text: "In a meeting", Emoji: ":calendar:"},
+			},
+		},
+		{
+			name:  "clear status after handoff",
+			users: nil,
+			wantStatuses: map[string]slackStatus{
+				"U1": {},
+				"U2": {Text: "In a meeting", Emoji: ":calendar:"},
+			},
+		},
+		{
+			name:  "set status again",
+			users: []oncallUser{jane},
+			wantStatuses: map[string]slackStatus{
+				"U1": onCallStatus,
+				"U2": {Text: "In a meeting", Emoji: ":calendar:"},
+			},
+		},
+		{
+			name:         "keep status changed by human after handoff",
+			users:        nil,
+			humanChanges: map[string]slackStatus{"U1": {Text: "Vacationing", Emoji: ":palm_tree:"}},
+			wantStatuses: map[string]slackStatus{
+				"U1": {Text: "Vacationing", Emoji: ":palm_tree:"},
+				"U2": {Text: "In a meeting", Emoji: ":calendar:"},
+			},
+		},
+	}
+
+	for _, step := range steps {
+		for userID, status := range step.humanChanges {
+			statuses[userID] = status
+		}
+		onCalls := []scheduleOnCall{{schedule: schedule, users: step.users}}
+		if err := s.syncStatuses(context.Background(), slackSync, onCalls); err != nil {
+			t.Fatalf("step %q: failed to sync statuses: %s", step.name, err)
+		}
+		if diff := cmp.Diff(step.wantStatuses, statuses); diff != "" {
+			t.Errorf("step %q: statuses mismatch (-want +got):\n%s", step.name, diff)
+		}
+	}
+
+	if got, want := expirations["U1"], int(until.Unix()); got != want {
+		t.Errorf("got status expiration %d, want shift end %d", got, want)
+	}
+	if len(s.statuses["team"]) != 0 {
+		t.Errorf("got tracked statuses %v, want none", s.statuses["team"])
+	}
+}
+
+func TestSyncStatusesRenewsStatusForConsecutiveShifts(t *testing.T) {
+	statuses := map[string]slackStatus{}
+	expirations := map[string]int{}
+	srv := newStatusTestServer(t, statuses, expirations)
+	defer srv.Close()
+
+	conn := &slackConnection{
+		statusClient: &slackStatusClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users:        slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	s := newSyncer(syncerParams{})
+	slackSync := runSlackSync{name: "team", slack: conn}
+	schedule := oncallSchedule{id: "S1", name: "Primary", slackStatus: newSlackStatus(ConfigStatus{}, "Primary")}
+	firstEnd := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	secondEnd := firstEnd.Add(7 * 24 * time.Hour)
+	thirdEnd := secondEnd.Add(7 * 24 * time.Hour)
+	onCallStatus := slackStatus{Text: "On call for Primary", Emoji: ":pager:"}
+
+	steps := []struct {
+		name           string
+		until          time.Time
+		slackChanges   map[string]slackStatus
+		wantStatus     slackStatus
+		wantExpiration time.Time
+	}{
+		{
+			name:           "set status for first shift",
+			until:          firstEnd,
+			wantStatus:     onCallStatus,
+			wantExpiration: firstEnd,
+		},
+		{
+			name:           "set expired status again for second shift",
+			until:          secondEnd,
+			slackChanges:   map[string]slackStatus{"U1": {}},
+			wantStatus:     onCallStatus,
+			wantExpiration: secondEnd,
+		},
+		{
+			name:           "extend status before it expires",
+			until:          thirdEnd,
+			wantStatus:     onCallStatus,
+			wantExpiration: thirdEnd,
+		},
+		{
+			name:           "keep status changed by human",
+			until:          thirdEnd.Add(7 * 24 * time.Hour),
+			slackChanges:   map[string]slackStatus{"U1": {Text: "Vacationing", Emoji: ":palm_tree:"}},
+			wantStatus:     slackStatus{Text: "Vacationing", Emoji: ":palm_tree:"},
+			wantExpiration: thirdEnd,
+		},
+	}
+
+	for _, step := range steps {
+		for userID, status := range step.slackChanges {
+			statuses[userID] = status
+		}
+		onCalls := []scheduleOnCall{{
+			schedule: schedule,
+			users:    []oncallUser{{id: "PD1", email: "jane@example.com", until: step.until}},
+		}}
+		if err := s.syncStatuses(context.Background(), slackSync, onCalls); err != nil {
+			t.Fatalf("step %q: failed to sync statuses: %s", step.name, err)
+		}
+		if diff := cmp.Diff(step.wantStatus, statuses["U1"]); diff != "" {
+			t.Errorf("step %q: status mismatch (-want +got):\n%s", step.name, diff)
+		}
+		if got, want := expirations["U1"], int(step.wantExpiration.Unix()); got != want {
+			t.Errorf("step %q: got status expiration %d, want %d", step.name, got, want)
+		}
+	}
+}
+
+// newStatusTestServer returns a fake Slack API server that keeps the user
+// statuses and their expirations in the given maps.
+func newStatusTestServer(t *testing.T, statuses map[string]slackStatus, expirations map[string]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %s", err)
+			return
+		}
+		userID := r.Form.Get("user")
+
+		switch r.URL.Path {
+		case "/users.profile.get":
+			status := statuses[userID]
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":      true,
+				"profile": slack.UserProfile{StatusText: status.Text, StatusEmoji: status.Emoji},
+			})
+		case "/users.profile.set":
+			var profile slack.UserProfile
+			if err := json.Unmarshal([]byte(r.PostForm.Get("profile")), &profile); err != nil {
+				t.Errorf("failed to decode profile: %s", err)
+				return
+			}
+			statuses[userID] = slackStatus{Text: profile.StatusText, Emoji: profile.StatusEmoji}
+			expirations[userID] = profile.StatusExpiration
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
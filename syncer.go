@@ -8,50 +8,184 @@ import (
 	"os"
 	"strings"
 	"text/template"
+	"time"
 )
 
 type runSlackSync struct {
 	name           string
-	pdSchedules    pdSchedules
+	slack          *slackConnection
+	schedules      oncallSchedules
 	slackChannelID string
 	tmpl           *template.Template
+	topicMarkers   *ConfigTopicMarkers
+	purposeTmpl    *template.Template
 	dryRun         bool
 	pretendUsers   bool
+	teams          *runTeamsSync
+	mattermost     *runMattermostSync
+	discord        *runDiscordSync
+	webhook        *runWebhookSync
+	email          *runEmailSync
+	announcement   *runAnnouncement
+	bookmark       *runBookmark
+	reminder       *runReminder
+	roster         *runRoster
+	membership     *runChannelMembership
+	canvas         *runCanvas
+}
+
+// usesSlack returns whether the sync manages the Slack channel topic, the
+// channel purpose, a channel bookmark, a roster message, a channel canvas,
+// channel memberships, any Slack user groups, or Slack statuses.
+func (rss runSlackSync) usesSlack() bool {
+	if rss.tmpl != nil || rss.purposeTmpl != nil || rss.bookmark != nil || rss.roster != nil || rss.canvas != nil || rss.membership != nil {
+		return true
+	}
+	for _, schedule := range rss.schedules {
+		if len(schedule.userGroups) > 0 || schedule.slackStatus != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// usesShiftEnds returns whether the sync uses the end of the current shifts
+// of the given schedule, i.e., for a bookmark, the roster message, the canvas,
+// user group descriptions, or the expiration of Slack statuses.
+func (rss runSlackSync) usesShiftEnds(schedule oncallSchedule) bool {
+	return rss.bookmark != nil || rss.roster != nil || rss.canvas != nil || len(schedule.descriptions) > 0 || schedule.slackStatus != nil
+}
+
+// scheduleOnCall holds the users on call for a schedule at the time of a sync
+// run.
+type scheduleOnCall struct {
+	schedule oncallSchedule
+	users    []oncallUser
+}
+
+// slackUserIDs holds the Slack user IDs of all users on call for a schedule.
+// When rendered directly in a template, the IDs are joined by commas.
+type slackUserIDs []string
+
+func (ids slackUserIDs) String() string {
+	return strings.Join(ids, ",")
+}
+
+// onCallSummary holds the names of on-call users as exposed to templates.
+// When rendered directly in a template, the names are joined by commas.
+type onCallSummary struct {
+	Names []string
+	// Until is the earliest end of the users' current shifts, or zero if
+	// unknown.
+	Until time.Time
+}
+
+func (oc onCallSummary) String() string {
+	return strings.Join(oc.Names, ", ")
+}
+
+// add adds the given users to the summary, rendering shift ends in the given
+// location.
+func (oc *onCallSummary) add(users []oncallUser, loc *time.Location) {
+	for _, user := range users {
+		oc.Names = appendUnique(oc.Names, user.name)
+		if !user.until.IsZero() && (oc.Until.IsZero() || user.until.Before(oc.Until)) {
+			oc.Until = user.until.In(loc)
+		}
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"mentions": mentions,
+}
+
+// mentions renders the given Slack user IDs as a comma-separated list of
+// user mentions.
+func mentions(ids slackUserIDs) string {
+	userMentions := make([]string, 0, len(ids))
+	for _, id := range ids {
+		userMentions = append(userMentions, fmt.Sprintf("<@%s>", id))
+	}
+	return strings.Join(userMentions, ", ")
 }
 
 type syncerParams struct {
-	pdClient        *pagerDutyClient
-	slClient        *slackMetaClient
-	slackUsers      slackUsers
-	slackUserGroups UserGroups
+	pdClient      *pagerDutyClient
+	ogClient      *opsgenieClient
+	teamsClient   *teamsClient
+	mmClient      *mattermostClient
+	discordClient *discordClient
+	webhookClient *webhookClient
+	emailClient   *emailClient
+	stateStore    *stateStore
+	// slackConnections holds the Slack connections used by the syncs, keyed
+	// by connection name.
+	slackConnections map[string]*slackConnection
 }
 
 func (sp syncerParams) createSlackSyncs(ctx context.Context, cfg config) ([]runSlackSync, error) {
 	var slSyncs []runSlackSync
 
-	fmt.Println("Getting Slack channels")
-	slChannels, err := sp.slClient.getChannels(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get channels: %s", err)
-	}
-	fmt.Printf("Got %d Slack channel(s)\n", len(slChannels))
-
 	for _, cfgSlSync := range cfg.SlackSyncs {
 		slSync := runSlackSync{
 			name:         cfgSlSync.Name,
+			slack:        sp.slackConnections[cfgSlSync.SlackConnection],
 			pretendUsers: cfgSlSync.PretendUsers,
+			topicMarkers: cfgSlSync.TopicMarkers,
 			dryRun:       cfgSlSync.DryRun,
 		}
+		if slSync.slack == nil {
+			return nil, fmt.Errorf("failed to create slack sync %q: Slack connection %q is not configured", slSync.name, cfgSlSync.SlackConnection)
+		}
+		slChannels := slSync.slack.channels
 
 		if cfgSlSync.Template == "" {
 			fmt.Printf("Slack sync %s: skipping topic handling because template is undefined\n", slSync.name)
 		} else {
 			var err error
-			slSync.tmpl, err = template.New("topic").Parse(cfgSlSync.Template)
+			slSync.tmpl, err = template.New("topic").Funcs(templateFuncs).Parse(cfgSlSync.Template)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create slack sync %q: failed to parse template %q: %s", slSync.name, cfgSlSync.Template, err)
 			}
+		}
 
+		if cfgSlSync.PurposeTemplate != "" {
+			var err error
+			slSync.purposeTmpl, err = template.New("purpose").Funcs(templateFuncs).Parse(cfgSlSync.PurposeTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to parse purpose template %q: %s", slSync.name, cfgSlSync.PurposeTemplate, err)
+			}
+		}
+
+		if cfgSlSync.Reminder != nil {
+			var err error
+			slSync.reminder, err = createReminder(*cfgSlSync.Reminder)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create reminder: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Bookmark != nil {
+			var err error
+			slSync.bookmark, err = createBookmark(*cfgSlSync.Bookmark)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create bookmark: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Roster != nil {
+			slSync.roster = createRoster(*cfgSlSync.Roster)
+		}
+
+		if cfgSlSync.Canvas != nil {
+			var err error
+			slSync.canvas, err = createCanvas(*cfgSlSync.Canvas)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create canvas: %s", slSync.name, err)
+			}
+		}
+
+		if slSync.tmpl != nil || slSync.purposeTmpl != nil || slSync.bookmark != nil || slSync.roster != nil || slSync.canvas != nil {
 			cfgChannel := cfgSlSync.Channel
 			slChannel := slChannels.find(cfgChannel.ID, cfgChannel.Name)
 			if slChannel == nil {
@@ -61,38 +195,129 @@ func (sp syncerParams) createSlackSyncs(ctx context.Context, cfg config) ([]runS
 			fmt.Printf("Slack sync %s: found Slack channel %q (ID %s)\n", slSync.name, slChannel.Name, slChannel.ID)
 		}
 
-		pdSchedules := pdSchedules{}
-		fmt.Printf("Slack sync %s: Getting PagerDuty schedules\n", slSync.name)
-		for _, schedule := range cfgSlSync.Schedules {
-			pdSchedule, err := sp.pdClient.getSchedule(ctx, schedule.ID, schedule.Name)
+		var err error
+		if cfgSlSync.ChannelMembership != nil {
+			slSync.membership, err = sp.createChannelMembership(slSync.name, *cfgSlSync.ChannelMembership, slChannels)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create channel membership: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Announcement != nil {
+			slSync.announcement, err = sp.createAnnouncement(slSync.name, cfgSlSync, slChannels)
 			if err != nil {
-				return nil, fmt.Errorf("failed to create slack sync %q: failed to get schedule %s: %s", slSync.name, schedule, err)
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create announcement: %s", slSync.name, err)
 			}
+		}
 
-			if pdSchedule == nil {
-				return nil, fmt.Errorf("failed to create slack sync %q: schedule %s not found", slSync.name, schedule)
+		var provider oncallProvider
+		if cfgSlSync.usesProvider() {
+			provider, err = sp.getProvider(cfgSlSync.Provider)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: %s", slSync.name, err)
 			}
+		}
 
-			for _, cfgUserGroup := range schedule.UserGroups {
-				ug := sp.slackUserGroups.find(cfgUserGroup)
-				if ug == nil {
-					return nil, fmt.Errorf("failed to create slack sync %q: user group %s not found", slSync.name, cfgUserGroup)
+		schedules := oncallSchedules{}
+		fmt.Printf("Slack sync %s: Getting %s schedules\n", slSync.name, cfgSlSync.Provider)
+		for _, cfgSchedule := range cfgSlSync.Schedules {
+			schedProvider := provider
+			switch {
+			case cfgSchedule.ICS != nil:
+				schedProvider, err = newICSCalendar(*cfgSchedule.ICS)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create slack sync %q: failed to create iCalendar schedule %s: %s", slSync.name, cfgSchedule, err)
 				}
-				fmt.Printf("Slack sync %s: assigning user group %s to schedule %s\n", slSync.name, ug, pdSchedule)
-				pdSchedule.userGroups = append(pdSchedule.userGroups, *ug)
+			case cfgSchedule.Rotation != nil:
+				schedProvider, err = newStaticRotation(*cfgSchedule.Rotation)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create slack sync %q: failed to create rotation %s: %s", slSync.name, cfgSchedule, err)
+				}
+			}
+
+			schedule, err := schedProvider.getSchedule(ctx, cfgSchedule.ID, cfgSchedule.Name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to get schedule %s: %s", slSync.name, cfgSchedule, err)
 			}
 
-			pdSchedules.ensureSchedule(*pdSchedule)
+			if schedule == nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: schedule %s not found", slSync.name, cfgSchedule)
+			}
 
-			for _, cfgUserGroup := range schedule.UserGroups {
-				ug := sp.slackUserGroups.find(cfgUserGroup)
-				if ug == nil {
-					return nil, fmt.Errorf("failed to create slack sync %q: user group %s not found", slSync.name, ug)
+			schedule.provider = schedProvider
+			if cfgSchedule.Status != nil {
+				if slSync.slack.statusClient == nil {
+					return nil, fmt.Errorf("failed to create slack sync %q: setting the status for schedule %s requires a Slack user token", slSync.name, cfgSchedule)
 				}
+				schedule.slackStatus = newSlackStatus(*cfgSchedule.Status, schedule.name)
+			}
+
+			if err := slSync.slack.assignUserGroups(ctx, slSync.name, schedule, cfgSchedule.UserGroups, slSync.dryRun); err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: %s", slSync.name, err)
+			}
+			schedule.withShiftEnds = slSync.usesShiftEnds(*schedule)
+
+			schedules.ensureSchedule(*schedule)
+		}
+
+		if len(cfgSlSync.EscalationPolicies) > 0 {
+			fmt.Printf("Slack sync %s: Getting PagerDuty escalation policies\n", slSync.name)
+		}
+		for _, cfgEscalationPolicy := range cfgSlSync.EscalationPolicies {
+			schedule, err := sp.pdClient.getEscalationPolicy(ctx, cfgEscalationPolicy.ID, cfgEscalationPolicy.Name, cfgEscalationPolicy.Levels)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to get escalation policy %s: %s", slSync.name, cfgEscalationPolicy, err)
+			}
+
+			if schedule == nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: escalation policy %s not found", slSync.name, cfgEscalationPolicy)
+			}
+
+			schedule.provider = sp.pdClient
+
+			if err := slSync.slack.assignUserGroups(ctx, slSync.name, schedule, cfgEscalationPolicy.UserGroups, slSync.dryRun); err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: %s", slSync.name, err)
+			}
+
+			schedules.ensureSchedule(*schedule)
+		}
+		slSync.schedules = schedules
+		fmt.Printf("Slack sync %s: found %d schedule(s) and escalation policy(ies)\n", slSync.name, len(schedules))
+
+		if cfgSlSync.Teams != nil {
+			slSync.teams, err = sp.createTeamsSync(ctx, slSync.name, cfgSlSync, schedules)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create Microsoft Teams sync: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Mattermost != nil {
+			slSync.mattermost, err = sp.createMattermostSync(ctx, slSync.name, cfgSlSync, schedules)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create Mattermost sync: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Discord != nil {
+			slSync.discord, err = sp.createDiscordSync(ctx, slSync.name, cfgSlSync)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create Discord sync: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Webhook != nil {
+			slSync.webhook, err = sp.createWebhookSync(*cfgSlSync.Webhook)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create webhook sync: %s", slSync.name, err)
+			}
+		}
+
+		if cfgSlSync.Email != nil {
+			slSync.email, err = sp.createEmailSync(*cfgSlSync.Email)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create slack sync %q: failed to create email sync: %s", slSync.name, err)
 			}
 		}
-		slSync.pdSchedules = pdSchedules
-		fmt.Printf("Slack sync %s: found %d PagerDuty schedule(s)\n", slSync.name, len(pdSchedules))
 
 		slSyncs = append(slSyncs, slSync)
 	}
@@ -100,23 +325,126 @@ func (sp syncerParams) createSlackSyncs(ctx context.Context, cfg config) ([]runS
 	return slSyncs, nil
 }
 
+// getProvider returns the on-call provider client for the given provider name.
+func (sp syncerParams) getProvider(name string) (oncallProvider, error) {
+	switch name {
+	case providerPagerDuty:
+		if sp.pdClient == nil {
+			return nil, errors.New("PagerDuty client is not configured")
+		}
+		return sp.pdClient, nil
+	case providerOpsgenie:
+		if sp.ogClient == nil {
+			return nil, errors.New("Opsgenie client is not configured")
+		}
+		return sp.ogClient, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", name)
+	}
+}
+
+// assignUserGroups assigns the configured user groups to the schedule. User
+// groups that are missing or disabled are created or re-enabled if
+// configured to.
+func (conn *slackConnection) assignUserGroups(ctx context.Context, slSyncName string, schedule *oncallSchedule, cfgUserGroups UserGroups, dryRun bool) error {
+	for _, cfgUserGroup := range cfgUserGroups {
+		ug := conn.userGroups.find(cfgUserGroup)
+		if ug == nil {
+			if !cfgUserGroup.Create {
+				return fmt.Errorf("user group %s not found", cfgUserGroup)
+			}
+
+			var err error
+			ug, err = conn.client.ensureUserGroup(ctx, cfgUserGroup, dryRun)
+			if err != nil {
+				return fmt.Errorf("failed to ensure user group %s: %s", cfgUserGroup, err)
+			}
+			if ug == nil {
+				fmt.Printf("[DRY RUN] Slack sync %s: not assigning missing user group %s to schedule %s\n", slSyncName, cfgUserGroup, schedule)
+				continue
+			}
+			conn.userGroups = append(conn.userGroups, *ug)
+		}
+		fmt.Printf("Slack sync %s: assigning user group %s to schedule %s\n", slSyncName, ug, schedule)
+		schedule.userGroups = append(schedule.userGroups, *ug)
+
+		if cfgUserGroup.DescriptionTemplate != "" {
+			tmpl, err := template.New("description").Parse(cfgUserGroup.DescriptionTemplate)
+			if err != nil {
+				return fmt.Errorf("failed to parse description template %q of user group %s: %s", cfgUserGroup.DescriptionTemplate, cfgUserGroup, err)
+			}
+			loc := time.UTC
+			if cfgUserGroup.TimeZone != "" {
+				loc, err = time.LoadLocation(cfgUserGroup.TimeZone)
+				if err != nil {
+					return fmt.Errorf("failed to load time zone %q of user group %s: %s", cfgUserGroup.TimeZone, cfgUserGroup, err)
+				}
+			}
+			if schedule.descriptions == nil {
+				schedule.descriptions = map[string]userGroupDescription{}
+			}
+			schedule.descriptions[ug.ID] = userGroupDescription{tmpl: tmpl, loc: loc}
+		}
+	}
+
+	return nil
+}
+
 type syncer struct {
 	syncerParams
+	// previousOnCalls holds the on-calls last delivered to each handoff
+	// target, keyed by sync name and target (see handoffKey).
+	previousOnCalls map[string][]scheduleOnCall
+	// handoffEmails holds the schedule IDs and recipients of the handoff
+	// emails sent for a handoff that was not fully delivered yet, keyed by
+	// sync name.
+	handoffEmails map[string][]string
+	// handoffAnnouncements holds the IDs of the schedules whose announcement
+	// was posted for a handoff that was not fully announced yet, keyed by sync
+	// name.
+	handoffAnnouncements map[string][]string
+	// bookmarkIDs holds the IDs of the managed Slack bookmarks, keyed by sync
+	// name.
+	bookmarkIDs map[string]string
+	// statuses holds the Slack statuses set by pdsync by Slack user ID, keyed
+	// by sync name.
+	statuses map[string]map[string]setSlackStatus
+	// sentReminders holds the start times of the shifts reminded of, keyed
+	// by schedule ID, user ID, and start time.
+	sentReminders map[string]time.Time
+	// rosters holds the roster messages, keyed by sync name.
+	rosters map[string]rosterMessage
+	// channelMembers holds the IDs of the users that pdsync invited by
+	// channel ID, keyed by sync name.
+	channelMembers map[string]map[string][]string
+	// canvases holds the channel canvases, keyed by sync name.
+	canvases map[string]canvasDocument
 }
 
 func newSyncer(sp syncerParams) *syncer {
 	return &syncer{
-		syncerParams: sp,
+		syncerParams:         sp,
+		previousOnCalls:      map[string][]scheduleOnCall{},
+		handoffEmails:        map[string][]string{},
+		handoffAnnouncements: map[string][]string{},
+		bookmarkIDs:          map[string]string{},
+		statuses:             map[string]map[string]setSlackStatus{},
+		sentReminders:        map[string]time.Time{},
+		rosters:              map[string]rosterMessage{},
+		channelMembers:       map[string]map[string][]string{},
+		canvases:             map[string]canvasDocument{},
 	}
 }
 
 func (s *syncer) Run(ctx context.Context, slackSyncs []runSlackSync, failFast bool) error {
+	var runErr error
 	for _, slackSync := range slackSyncs {
 		err := s.runSlackSync(ctx, slackSync)
 		if err != nil {
 			msg := fmt.Sprintf("failed to run Slack sync %s: %s", slackSync.name, err)
 			if failFast || ctx.Err() != nil {
-				return errors.New(msg)
+				runErr = errors.New(msg)
+				break
 			}
 
 			formattedMsg := strings.ToUpper(string(msg[0])) + msg[1:]
@@ -124,53 +452,342 @@ func (s *syncer) Run(ctx context.Context, slackSyncs []runSlackSync, failFast bo
 		}
 	}
 
+	// Persist the state even if a sync failed so that whatever was done before
+	// the failure is not repeated.
+	if err := s.persistState(); err != nil {
+		if runErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to persist state: %s\n", err)
+			return runErr
+		}
+		return fmt.Errorf("failed to persist state: %s", err)
+	}
+
+	return runErr
+}
+
+// restoreState loads the state persisted by a previous pdsync process, if
+// a state store is configured.
+func (s *syncer) restoreState() error {
+	if s.stateStore == nil {
+		return nil
+	}
+
+	state, err := s.stateStore.load()
+	if err != nil {
+		return err
+	}
+	for syncName, pOnCalls := range state.OnCalls {
+		s.previousOnCalls[syncName] = restoreOnCalls(pOnCalls)
+	}
+	for syncName, keys := range state.HandoffEmails {
+		s.handoffEmails[syncName] = keys
+	}
+	for syncName, scheduleIDs := range state.HandoffAnnouncements {
+		s.handoffAnnouncements[syncName] = scheduleIDs
+	}
+	for syncName, bookmarkID := range state.Bookmarks {
+		s.bookmarkIDs[syncName] = bookmarkID
+	}
+	for syncName, statuses := range state.Statuses {
+		s.statuses[syncName] = statuses
+	}
+	for key, start := range state.Reminders {
+		s.sentReminders[key] = start
+	}
+	for syncName, msg := range state.Rosters {
+		s.rosters[syncName] = msg
+	}
+	for syncName, members := range state.ChannelMembers {
+		s.channelMembers[syncName] = members
+	}
+	for syncName, doc := range state.Canvases {
+		s.canvases[syncName] = doc
+	}
+
 	return nil
 }
 
+func (s *syncer) persistState() error {
+	if s.stateStore == nil {
+		return nil
+	}
+
+	state := newPersistedState()
+	for syncName, onCalls := range s.previousOnCalls {
+		state.OnCalls[syncName] = persistOnCalls(onCalls)
+	}
+	for syncName, keys := range s.handoffEmails {
+		state.HandoffEmails[syncName] = keys
+	}
+	for syncName, scheduleIDs := range s.handoffAnnouncements {
+		state.HandoffAnnouncements[syncName] = scheduleIDs
+	}
+	for syncName, bookmarkID := range s.bookmarkIDs {
+		state.Bookmarks[syncName] = bookmarkID
+	}
+	for syncName, statuses := range s.statuses {
+		state.Statuses[syncName] = statuses
+	}
+	for key, start := range s.sentReminders {
+		state.Reminders[key] = start
+	}
+	for syncName, msg := range s.rosters {
+		state.Rosters[syncName] = msg
+	}
+	for syncName, members := range s.channelMembers {
+		state.ChannelMembers[syncName] = members
+	}
+	for syncName, doc := range s.canvases {
+		state.Canvases[syncName] = doc
+	}
+
+	return s.stateStore.save(state)
+}
+
+// runSlackSync runs all parts of the given sync. Reminders only depend on
+// upcoming shifts, so they are sent even if syncing the current on-calls
+// fails.
 func (s *syncer) runSlackSync(ctx context.Context, slackSync runSlackSync) error {
+	err := s.syncOnCalls(ctx, slackSync)
+
+	if slackSync.reminder != nil {
+		if rErr := s.runReminder(ctx, *slackSync.reminder, slackSync); rErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to send reminders: %s", rErr))
+		}
+	}
+
+	return err
+}
+
+// syncOnCalls updates all targets of the given sync with the current
+// on-calls.
+func (s *syncer) syncOnCalls(ctx context.Context, slackSync runSlackSync) error {
 	if !slackSync.dryRun {
-		joined, err := s.slClient.joinChannel(ctx, slackSync.slackChannelID)
-		if err != nil {
-			if strings.Contains(err.Error(), "missing_scope") {
-				fmt.Printf(`cannot automatically join channel with ID %s because of missing scope "channels:join" -- please add the scope or join pdsync manually`, slackSync.slackChannelID)
-			} else {
-				return fmt.Errorf("failed to join channel with ID %s: %s", slackSync.slackChannelID, err)
+		channelIDs := []string{}
+		if slackSync.slackChannelID != "" {
+			channelIDs = append(channelIDs, slackSync.slackChannelID)
+		}
+		if slackSync.announcement != nil {
+			channelIDs = appendUnique(channelIDs, slackSync.announcement.slackChannelID)
+		}
+		if slackSync.membership != nil {
+			for _, channelID := range slackSync.membership.slackChannelIDs {
+				channelIDs = appendUnique(channelIDs, channelID)
 			}
 		}
-		if joined {
-			fmt.Printf("joined channel with ID %s\n", slackSync.slackChannelID)
+		for _, channelID := range channelIDs {
+			if err := s.joinChannel(ctx, slackSync.slack, channelID); err != nil {
+				return err
+			}
 		}
 	}
 
-	ocgs := oncallGroups{}
-	slackUserIDByScheduleName := map[string]string{}
-	for _, schedule := range slackSync.pdSchedules {
+	onCalls, err := s.getOnCalls(ctx, slackSync.schedules)
+	if err != nil {
+		return err
+	}
+
+	// The targets are independent of each other, so a failing target must not
+	// keep the others from being updated.
+	var errs []error
+	if slackSync.usesSlack() {
+		if err := s.syncSlack(ctx, slackSync, onCalls); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if slackSync.teams != nil {
+		if err := s.runTeamsSync(ctx, *slackSync.teams, onCalls, slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync Microsoft Teams: %s", err))
+		}
+	}
+
+	if slackSync.mattermost != nil {
+		if err := s.runMattermostSync(ctx, *slackSync.mattermost, onCalls, slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync Mattermost: %s", err))
+		}
+	}
+
+	if slackSync.discord != nil {
+		if err := s.runDiscordSync(ctx, *slackSync.discord, onCalls, slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync Discord: %s", err))
+		}
+	}
+
+	// The handoff targets are independent of each other, too. Each target
+	// remembers the on-calls it was last notified of, so that only failed
+	// targets are retried on the next run. Dry runs notify nobody, so they
+	// must not advance the on-calls either.
+	if slackSync.webhook != nil {
+		key := handoffKey(slackSync.name, "webhook")
+		if err := s.runWebhookSync(ctx, *slackSync.webhook, slackSync.name, slackSync.slack.users, s.previousOnCalls[key], onCalls, slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync webhook: %s", err))
+		} else if !slackSync.dryRun {
+			s.previousOnCalls[key] = onCalls
+		}
+	}
+
+	if slackSync.email != nil {
+		key := handoffKey(slackSync.name, "email")
+		if err := s.runEmailSync(*slackSync.email, slackSync.name, s.previousOnCalls[key], onCalls, slackSync.dryRun); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync email: %s", err))
+		} else if !slackSync.dryRun {
+			s.previousOnCalls[key] = onCalls
+		}
+	}
+
+	if slackSync.announcement != nil {
+		key := handoffKey(slackSync.name, "announcement")
+		if err := s.runAnnouncement(ctx, *slackSync.announcement, slackSync, s.previousOnCalls[key], onCalls); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post announcement: %s", err))
+		} else if !slackSync.dryRun {
+			s.previousOnCalls[key] = onCalls
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// handoffKey returns the key of the on-calls last delivered to the given
+// handoff target of a sync.
+func handoffKey(syncName, target string) string {
+	return syncName + "/" + target
+}
+
+func (s *syncer) joinChannel(ctx context.Context, conn *slackConnection, channelID string) error {
+	joined, err := conn.client.joinChannel(ctx, channelID)
+	if err != nil {
+		if strings.Contains(err.Error(), "missing_scope") {
+			fmt.Printf(`cannot automatically join channel with ID %s because of missing scope "channels:join" -- please add the scope or join pdsync manually`, channelID)
+		} else {
+			return fmt.Errorf("failed to join channel with ID %s: %s", channelID, err)
+		}
+	}
+	if joined {
+		fmt.Printf("joined channel with ID %s\n", channelID)
+	}
+	return nil
+}
+
+func (s *syncer) getOnCalls(ctx context.Context, schedules oncallSchedules) ([]scheduleOnCall, error) {
+	onCalls := make([]scheduleOnCall, 0, len(schedules))
+	for _, schedule := range schedules {
 		fmt.Printf("Processing schedule %s\n", schedule)
-		onCallUser, err := s.pdClient.getOnCallUser(ctx, schedule)
+		onCallUsers, err := schedule.provider.getOnCallUsers(ctx, schedule)
 		if err != nil {
-			return fmt.Errorf("failed to get on call user for schedule %q: %s", schedule.name, err)
+			return nil, fmt.Errorf("failed to get on call users for schedule %q: %s", schedule.name, err)
 		}
+		onCalls = append(onCalls, scheduleOnCall{
+			schedule: schedule,
+			users:    onCallUsers,
+		})
+	}
 
-		slUser := s.slackUsers.findByPDUser(onCallUser)
-		if slUser == nil {
-			return fmt.Errorf("failed to find Slack user for PD user %s", pagerDutyUserString(onCallUser))
+	return onCalls, nil
+}
+
+// onCallsChanged returns whether the on-call users of any schedule differ
+// between the given on-calls.
+func onCallsChanged(prevOnCalls, onCalls []scheduleOnCall) bool {
+	if len(prevOnCalls) != len(onCalls) {
+		return true
+	}
+	for _, onCall := range onCalls {
+		prevUsers, ok := findOnCallUsers(prevOnCalls, onCall.schedule.id)
+		if !ok || !sameOnCallUsers(prevUsers, onCall.users) {
+			return true
 		}
+	}
+	return false
+}
 
-		for _, userGroup := range schedule.userGroups {
-			fmt.Printf("Ensuring member %s for user group %s\n", slUser.id, userGroup)
-			ocgs.getOrCreate(userGroup).ensureMember(slUser.id)
+// findOnCallUsers returns the on-call users of the schedule with the given ID
+// and whether the schedule was found.
+func findOnCallUsers(onCalls []scheduleOnCall, scheduleID string) ([]oncallUser, bool) {
+	for _, onCall := range onCalls {
+		if onCall.schedule.id == scheduleID {
+			return onCall.users, true
 		}
+	}
+	return nil, false
+}
 
-		slUserID := slUser.id
-		if slackSync.pretendUsers {
-			slUserID = fmt.Sprintf(`\%s`, slUserID)
+// sameOnCallUsers returns whether both lists contain the same users,
+// irrespective of order.
+func sameOnCallUsers(a, b []oncallUser) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, user := range a {
+		if !containsOnCallUser(b, user) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsOnCallUser(users []oncallUser, user oncallUser) bool {
+	for _, u := range users {
+		if u.id == user.id {
+			return true
 		}
+	}
+	return false
+}
 
-		cleanScheduleName := notAlphaNumRE.ReplaceAllString(schedule.name, "")
-		slackUserIDByScheduleName[cleanScheduleName] = slUserID
+// syncSlack updates the Slack user groups, the channel topic, purpose,
+// bookmark, roster message, and canvas, as well as channel memberships and
+// user statuses.
+func (s *syncer) syncSlack(ctx context.Context, slackSync runSlackSync, onCalls []scheduleOnCall) error {
+	// Set up the user groups of all schedules before adding members so that
+	// descriptions are rendered even while nobody is on call.
+	ocgs := oncallGroups{}
+	for _, onCall := range onCalls {
+		for _, userGroup := range onCall.schedule.userGroups {
+			ocg := ocgs.getOrCreate(userGroup)
+			if desc, ok := onCall.schedule.descriptions[userGroup.ID]; ok && ocg.descriptionTmpl == nil {
+				ocg.descriptionTmpl = desc.tmpl
+				ocg.descriptionLoc = desc.loc
+			}
+		}
+	}
+
+	slackUserIDsByScheduleName := map[string]slackUserIDs{}
+	for _, onCall := range onCalls {
+		for _, userGroup := range onCall.schedule.userGroups {
+			ocg := ocgs.getOrCreate(userGroup)
+			ocg.onCall.add(onCall.users, ocg.descriptionLoc)
+		}
+
+		slUserIDs := slackUserIDs{}
+		for _, onCallUser := range onCall.users {
+			slUser := slackSync.slack.users.findByOncallUser(onCallUser)
+			if slUser == nil {
+				return fmt.Errorf("failed to find Slack user for on-call user %s", onCallUser)
+			}
+
+			for _, userGroup := range onCall.schedule.userGroups {
+				fmt.Printf("Ensuring member %s for user group %s\n", slUser.id, userGroup)
+				ocgs.getOrCreate(userGroup).ensureMember(slUser.id)
+			}
+
+			slUserID := slUser.id
+			if slackSync.pretendUsers {
+				slUserID = fmt.Sprintf(`\%s`, slUserID)
+			}
+			slUserIDs = append(slUserIDs, slUserID)
+		}
+
+		slackUserIDsByScheduleName[templateVarName(onCall.schedule.name)] = slUserIDs
+	}
+
+	for _, ocg := range ocgs {
+		if err := ocg.renderDescription(); err != nil {
+			return err
+		}
 	}
 
-	if err := s.slClient.updateOncallGroupMembers(ctx, ocgs, slackSync.dryRun); err != nil {
+	if err := slackSync.slack.client.updateOncallGroupMembers(ctx, ocgs, slackSync.dryRun); err != nil {
 		return fmt.Errorf("failed to update on-call user group members: %s", err)
 	}
 
@@ -178,18 +795,91 @@ func (s *syncer) runSlackSync(ctx context.Context, slackSync runSlackSync) error
 		fmt.Println("Skipping topic update")
 	} else {
 		var buf bytes.Buffer
-		fmt.Printf("Executing template with Slack user IDs by schedule name: %s\n", slackUserIDByScheduleName)
-		err := slackSync.tmpl.Execute(&buf, slackUserIDByScheduleName)
+		fmt.Printf("Executing template with Slack user IDs by schedule name: %s\n", slackUserIDsByScheduleName)
+		err := slackSync.tmpl.Execute(&buf, slackUserIDsByScheduleName)
 		if err != nil {
 			return fmt.Errorf("failed to render template: %s", err)
 		}
 
 		topic := buf.String()
-		err = s.slClient.updateTopic(ctx, slackSync.slackChannelID, topic, slackSync.dryRun)
+		err = slackSync.slack.client.updateTopic(ctx, slackSync.slackChannelID, topic, slackSync.topicMarkers, slackSync.dryRun)
 		if err != nil {
 			return fmt.Errorf("failed to update topic: %s", err)
 		}
 	}
 
+	if slackSync.purposeTmpl != nil {
+		var buf bytes.Buffer
+		fmt.Printf("Executing purpose template with Slack user IDs by schedule name: %s\n", slackUserIDsByScheduleName)
+		err := slackSync.purposeTmpl.Execute(&buf, slackUserIDsByScheduleName)
+		if err != nil {
+			return fmt.Errorf("failed to render purpose template: %s", err)
+		}
+
+		purpose := buf.String()
+		err = slackSync.slack.client.updatePurpose(ctx, slackSync.slackChannelID, purpose, slackSync.dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to update purpose: %s", err)
+		}
+	}
+
+	if slackSync.bookmark != nil {
+		if err := s.runBookmark(ctx, *slackSync.bookmark, slackSync, onCalls); err != nil {
+			return fmt.Errorf("failed to update bookmark: %s", err)
+		}
+	}
+
+	if slackSync.roster != nil {
+		if err := s.runRoster(ctx, *slackSync.roster, slackSync, onCalls); err != nil {
+			return fmt.Errorf("failed to update roster: %s", err)
+		}
+	}
+
+	if slackSync.membership != nil {
+		if err := s.runChannelMembership(ctx, *slackSync.membership, slackSync, onCalls); err != nil {
+			return fmt.Errorf("failed to sync channel membership: %s", err)
+		}
+	}
+
+	if err := s.syncStatuses(ctx, slackSync, onCalls); err != nil {
+		return fmt.Errorf("failed to sync statuses: %s", err)
+	}
+
+	// The canvas goes last since taking over an existing channel canvas may
+	// fail persistently, which must not hold back the remaining steps.
+	if slackSync.canvas != nil {
+		if err := s.runCanvas(ctx, *slackSync.canvas, slackSync, onCalls); err != nil {
+			return fmt.Errorf("failed to update canvas: %s", err)
+		}
+	}
+
 	return nil
 }
+
+// templateVarName returns the template variable name for the given schedule
+// name. Go template variables support alphanumeric characters only.
+func templateVarName(scheduleName string) string {
+	return notAlphaNumRE.ReplaceAllString(scheduleName, "")
+}
+
+// includesSchedule returns whether a target restricted to the given schedule
+// names covers the named schedule. An empty list covers all schedules.
+func includesSchedule(scheduleNames []string, name string) bool {
+	return len(scheduleNames) == 0 || containsString(scheduleNames, name)
+}
+
+func containsString(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, s string) []string {
+	if containsString(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slack-go/slack"
+)
+
+// fakeOnCallProvider returns fixed on-call users by schedule ID.
+type fakeOnCallProvider struct {
+	users map[string][]oncallUser
+}
+
+func (fop *fakeOnCallProvider) getSchedule(context.Context, string, string) (*oncallSchedule, error) {
+	return nil, nil
+}
+
+func (fop *fakeOnCallProvider) getOnCallUsers(_ context.Context, schedule oncallSchedule) ([]oncallUser, error) {
+	return fop.users[schedule.id], nil
+}
+
+func TestTemplateSlackUserIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		inTmpl   string
+		inData   map[string]slackUserIDs
+		wantText string
+	}{
+		{
+			name:     "single user rendered directly",
+			inTmpl:   "on-call: <@{{.Primary}}>",
+			inData:   map[string]slackUserIDs{"Primary": {"U1"}},
+			wantText: "on-call: <@U1>",
+		},
+		{
+			name:     "multiple users rendered directly",
+			inTmpl:   "on-call: {{.Primary}}",
+			inData:   map[string]slackUserIDs{"Primary": {"U1", "U2"}},
+			wantText: "on-call: U1,U2",
+		},
+		{
+			name:     "multiple users rendered as mentions",
+			inTmpl:   "on-call: {{mentions .Primary}}",
+			inData:   map[string]slackUserIDs{"Primary": {"U1", "U2"}},
+			wantText: "on-call: <@U1>, <@U2>",
+		},
+		{
+			name:     "no users rendered as mentions",
+			inTmpl:   "on-call: {{mentions .Primary}}",
+			inData:   map[string]slackUserIDs{"Primary": {}},
+			wantText: "on-call: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New("topic").Funcs(templateFuncs).Parse(tt.inTmpl)
+			if err != nil {
+				t.Fatalf("failed to parse template: %s", err)
+			}
+
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, tt.inData); err != nil {
+				t.Fatalf("failed to execute template: %s", err)
+			}
+
+			if gotText := buf.String(); gotText != tt.wantText {
+				t.Errorf("got text %q, want %q", gotText, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestRunSlackSyncNotifiesWebhookDespiteFailingTargets(t *testing.T) {
+	var webhooks int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/webhook" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		webhooks++
+	}))
+	defer srv.Close()
+
+	s := newSyncer(syncerParams{
+		teamsClient:   newTeamsClient("tenant", "client", "secret", srv.URL+"/graph", srv.URL),
+		webhookClient: newWebhookClient(),
+	})
+	provider := &fakeOnCallProvider{users: map[string][]oncallUser{"S1": {{id: "PD1", name: "Jane Doe", email: "jane@example.com"}}}}
+	slackSync := runSlackSync{
+		name:      "team",
+		slack:     &slackConnection{},
+		schedules: oncallSchedules{{id: "S1", name: "Primary", provider: provider}},
+		teams:     &runTeamsSync{teamID: "team", channelID: "channel"},
+		webhook:   &runWebhookSync{url: srv.URL + "/webhook"},
+	}
+
+	if err := s.runSlackSync(context.Background(), slackSync); err == nil {
+		t.Error("got no error for failing Microsoft Teams sync, want one")
+	}
+	if webhooks != 1 {
+		t.Errorf("got %d webhook(s), want 1", webhooks)
+	}
+}
+
+func TestRunTracksHandoffTargetsIndependently(t *testing.T) {
+	var webhookFailing atomic.Bool
+	var webhooks atomic.Int32
+	whSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if webhookFailing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		webhooks.Add(1)
+	}))
+	defer whSrv.Close()
+	smtpSrv := newFakeSMTPServer(t)
+	defer smtpSrv.listener.Close()
+
+	whClient := newWebhookClient()
+	whClient.retryDelay = 0
+	st := newStateStore(filepath.Join(t.TempDir(), "state.json"))
+	s := newSyncer(syncerParams{
+		webhookClient: whClient,
+		emailClient:   newEmailClient(smtpSrv.listener.Addr().String(), "pdsync@example.com", "", ""),
+		stateStore:    st,
+	})
+	emailSync, err := s.createEmailSync(ConfigEmail{Template: "Hi {{.Recipient}}"})
+	if err != nil {
+		t.Fatalf("failed to create email sync: %s", err)
+	}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	provider := &fakeOnCallProvider{users: map[string][]oncallUser{"S1": {jane}}}
+	slackSyncs := []runSlackSync{
+		{
+			name:      "team",
+			slack:     &slackConnection{},
+			schedules: oncallSchedules{{id: "S1", name: "Primary", provider: provider}},
+			webhook:   &runWebhookSync{url: whSrv.URL},
+			email:     emailSync,
+		},
+	}
+
+	if err := s.Run(context.Background(), slackSyncs, true); err != nil {
+		t.Fatalf("failed to run initial sync: %s", err)
+	}
+
+	provider.users["S1"] = []oncallUser{john}
+	webhookFailing.Store(true)
+	if err := s.Run(context.Background(), slackSyncs, true); err == nil {
+		t.Fatal("got no error for failing webhook, want one")
+	}
+
+	restored := newSyncer(syncerParams{stateStore: st})
+	if err := restored.restoreState(); err != nil {
+		t.Fatalf("failed to restore state: %s", err)
+	}
+	gotUsers := map[string][]oncallUser{}
+	for key, onCalls := range restored.previousOnCalls {
+		gotUsers[key] = onCalls[0].users
+	}
+	wantUsers := map[string][]oncallUser{
+		"team/webhook": {jane},
+		"team/email":   {john},
+	}
+	if diff := cmp.Diff(wantUsers, gotUsers, cmp.AllowUnexported(oncallUser{})); diff != "" {
+		t.Errorf("persisted on-call users mismatch (-want +got):\n%s", diff)
+	}
+
+	webhookFailing.Store(false)
+	if err := s.Run(context.Background(), slackSyncs, true); err != nil {
+		t.Fatalf("failed to retry sync: %s", err)
+	}
+
+	if got := webhooks.Load(); got != 2 {
+		t.Errorf("got %d delivered webhook(s), want 2", got)
+	}
+	smtpSrv.mu.Lock()
+	defer smtpSrv.mu.Unlock()
+	var gotRecipients []string
+	for _, msg := range smtpSrv.messages {
+		gotRecipients = append(gotRecipients, msg.to)
+	}
+	if diff := cmp.Diff([]string{"john@example.com"}, gotRecipients); diff != "" {
+		t.Errorf("email recipients mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunSlackSyncDryRunKeepsPreviousOnCalls(t *testing.T) {
+	var webhooks int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhooks++
+	}))
+	defer srv.Close()
+
+	s := newSyncer(syncerParams{webhookClient: newWebhookClient()})
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	s.previousOnCalls[handoffKey("team", "webhook")] = []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}}}
+	provider := &fakeOnCallProvider{users: map[string][]oncallUser{"S1": {john}}}
+	slackSync := runSlackSync{
+		name:      "team",
+		slack:     &slackConnection{},
+		schedules: oncallSchedules{{id: "S1", name: "Primary", provider: provider}},
+		webhook:   &runWebhookSync{url: srv.URL},
+		dryRun:    true,
+	}
+
+	if err := s.runSlackSync(context.Background(), slackSync); err != nil {
+		t.Fatalf("failed to run dry-run sync: %s", err)
+	}
+	slackSync.dryRun = false
+	if err := s.runSlackSync(context.Background(), slackSync); err != nil {
+		t.Fatalf("failed to run sync: %s", err)
+	}
+
+	if webhooks != 1 {
+		t.Errorf("got %d webhook(s), want 1", webhooks)
+	}
+}
+
+func TestSyncSlackDescriptionWithoutOnCallUsers(t *testing.T) {
+	fsu := &fakeSlackUserGroups{
+		groups: []slack.UserGroup{
+			{ID: "G1", Name: "Primary", Handle: "primary", Description: "Currently: Jane Doe", Users: []string{"U1"}},
+		},
+	}
+	srv := httptest.NewServer(fsu.handler(t))
+	defer srv.Close()
+
+	schedule := oncallSchedule{
+		id:         "S1",
+		name:       "Primary",
+		userGroups: UserGroups{{ID: "G1", Name: "Primary", Handle: "primary"}},
+		descriptions: map[string]userGroupDescription{
+			"G1": {
+				tmpl: template.Must(template.New("description").Parse(`{{if .Names}}Currently: {{.}}{{else}}Nobody on call{{end}}`)),
+				loc:  time.UTC,
+			},
+		},
+	}
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users:  slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	s := newSyncer(syncerParams{})
+	slackSync := runSlackSync{name: "team", slack: conn, schedules: oncallSchedules{schedule}}
+
+	if err := s.syncSlack(context.Background(), slackSync, []scheduleOnCall{{schedule: schedule}}); err != nil {
+		t.Fatalf("failed to sync Slack: %s", err)
+	}
+
+	if diff := cmp.Diff([]string{"/usergroups.list", "/usergroups.update"}, fsu.requests); diff != "" {
+		t.Errorf("requests mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := fsu.groups[0].Description, "Nobody on call"; got != want {
+		t.Errorf("got description %q, want %q", got, want)
+	}
+	if diff := cmp.Diff([]string{"U1"}, fsu.groups[0].Users); diff != "" {
+		t.Errorf("members mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSyncSlackDescriptionTimeZone(t *testing.T) {
+	fsu := &fakeSlackUserGroups{
+		groups: []slack.UserGroup{
+			{ID: "G1", Name: "Primary", Handle: "primary", Users: []string{"U1"}},
+		},
+	}
+	srv := httptest.NewServer(fsu.handler(t))
+	defer srv.Close()
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %s", err)
+	}
+	schedule := oncallSchedule{
+		id:         "S1",
+		name:       "Primary",
+		userGroups: UserGroups{{ID: "G1", Name: "Primary", Handle: "primary"}},
+		descriptions: map[string]userGroupDescription{
+			"G1": {
+				tmpl: template.Must(template.New("description").Parse(`{{.}} until {{.Until.Format "15:04 MST"}}`)),
+				loc:  loc,
+			},
+		},
+	}
+	conn := &slackConnection{
+		client: &slackMetaClient{slackClient: slack.New("token", slack.OptionAPIURL(srv.URL+"/"))},
+		users:  slackUsers{{id: "U1", email: "jane@example.com"}},
+	}
+	s := newSyncer(syncerParams{})
+	slackSync := runSlackSync{name: "team", slack: conn, schedules: oncallSchedules{schedule}}
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com", until: time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)}
+
+	if err := s.syncSlack(context.Background(), slackSync, []scheduleOnCall{{schedule: schedule, users: []oncallUser{jane}}}); err != nil {
+		t.Fatalf("failed to sync Slack: %s", err)
+	}
+
+	if got, want := fsu.groups[0].Description, "Jane Doe until 10:00 CET"; got != want {
+		t.Errorf("got description %q, want %q", got, want)
+	}
+}
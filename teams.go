@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultTeamsGraphURL = "https://graph.microsoft.com/v1.0"
+	defaultTeamsLoginURL = "https://login.microsoftonline.com"
+)
+
+// teamsUserNames holds the display names of all users on call for a schedule.
+// When rendered directly in a template, the names are joined by commas.
+type teamsUserNames []string
+
+func (names teamsUserNames) String() string {
+	return strings.Join(names, ", ")
+}
+
+// teamsTemplateFuncs mirrors templateFuncs for Microsoft Teams. Since channel
+// descriptions do not support mentions, users are rendered by name.
+var teamsTemplateFuncs = template.FuncMap{
+	"mentions": func(names teamsUserNames) string {
+		return names.String()
+	},
+}
+
+type runTeamsSync struct {
+	teamID    string
+	channelID string
+	tmpl      *template.Template
+	tags      []runTeamsTag
+}
+
+type runTeamsTag struct {
+	id   string
+	name string
+	// schedules are the names of the schedules whose on-call users should be
+	// tag members. Empty means all schedules of the sync.
+	schedules []string
+}
+
+func (sp syncerParams) createTeamsSync(ctx context.Context, slSyncName string, cfgSlSync ConfigSlackSync, schedules oncallSchedules) (*runTeamsSync, error) {
+	cfgTeams := cfgSlSync.Teams
+	if sp.teamsClient == nil {
+		return nil, errors.New("Microsoft Teams client is not configured")
+	}
+
+	teamsSync := &runTeamsSync{
+		teamID:    cfgTeams.TeamID,
+		channelID: cfgTeams.ChannelID,
+	}
+
+	tmplString := cfgTeams.Template
+	if tmplString == "" {
+		tmplString = cfgSlSync.Template
+	}
+	if cfgTeams.ChannelID != "" && tmplString != "" {
+		var err error
+		teamsSync.tmpl, err = template.New("description").Funcs(teamsTemplateFuncs).Parse(tmplString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template %q: %s", tmplString, err)
+		}
+	}
+
+	if len(cfgTeams.Tags) == 0 {
+		return teamsSync, nil
+	}
+
+	fmt.Printf("Slack sync %s: Getting Microsoft Teams tags\n", slSyncName)
+	tags, err := sp.teamsClient.getTags(ctx, cfgTeams.TeamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %s", err)
+	}
+
+	for _, cfgTag := range cfgTeams.Tags {
+		var found *teamsTag
+		for _, tag := range tags {
+			if (cfgTag.ID != "" && tag.ID == cfgTag.ID) || (cfgTag.Name != "" && tag.DisplayName == cfgTag.Name) {
+				found = &tag
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("tag %s not found", cfgTag)
+		}
+
+		for _, schedName := range cfgTag.Schedules {
+			if !schedules.hasName(schedName) {
+				return nil, fmt.Errorf("tag %s references unknown schedule %q", cfgTag, schedName)
+			}
+		}
+
+		fmt.Printf("Slack sync %s: found Microsoft Teams tag %q (ID %s)\n", slSyncName, found.DisplayName, found.ID)
+		teamsSync.tags = append(teamsSync.tags, runTeamsTag{
+			id:        found.ID,
+			name:      found.DisplayName,
+			schedules: cfgTag.Schedules,
+		})
+	}
+
+	return teamsSync, nil
+}
+
+func (s *syncer) runTeamsSync(ctx context.Context, teamsSync runTeamsSync, onCalls []scheduleOnCall, dryRun bool) error {
+	usersByEmail := map[string]teamsUser{}
+	for _, onCall := range onCalls {
+		for _, user := range onCall.users {
+			if user.email == "" {
+				return fmt.Errorf("cannot find Microsoft Teams user for on-call user without email %s", user)
+			}
+			teamsUser, err := s.teamsClient.getUserByEmail(ctx, user.email)
+			if err != nil {
+				return fmt.Errorf("failed to get Microsoft Teams user for on-call user %s: %s", user, err)
+			}
+			if teamsUser == nil {
+				return fmt.Errorf("failed to find Microsoft Teams user for on-call user %s", user)
+			}
+			usersByEmail[user.email] = *teamsUser
+		}
+	}
+
+	for _, tag := range teamsSync.tags {
+		var userIDs []string
+		for _, onCall := range onCalls {
+			if !includesSchedule(tag.schedules, onCall.schedule.name) {
+				continue
+			}
+			for _, user := range onCall.users {
+				userIDs = appendUnique(userIDs, usersByEmail[user.email].ID)
+			}
+		}
+
+		if err := s.teamsClient.updateTagMembers(ctx, teamsSync.teamID, tag, userIDs, dryRun); err != nil {
+			return fmt.Errorf("failed to update tag members: %s", err)
+		}
+	}
+
+	if teamsSync.tmpl == nil {
+		fmt.Println("Skipping Microsoft Teams channel description update")
+		return nil
+	}
+
+	userNamesByScheduleName := map[string]teamsUserNames{}
+	for _, onCall := range onCalls {
+		userNames := teamsUserNames{}
+		for _, user := range onCall.users {
+			userNames = append(userNames, usersByEmail[user.email].DisplayName)
+		}
+		userNamesByScheduleName[templateVarName(onCall.schedule.name)] = userNames
+	}
+
+	var buf bytes.Buffer
+	fmt.Printf("Executing Microsoft Teams template with user names by schedule name: %s\n", userNamesByScheduleName)
+	if err := teamsSync.tmpl.Execute(&buf, userNamesByScheduleName); err != nil {
+		return fmt.Errorf("failed to render template: %s", err)
+	}
+
+	if err := s.teamsClient.updateChannelDescription(ctx, teamsSync.teamID, teamsSync.channelID, buf.String(), dryRun); err != nil {
+		return fmt.Errorf("failed to update channel description: %s", err)
+	}
+
+	return nil
+}
+
+type teamsUser struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type teamsTag struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type teamsTagMember struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+}
+
+type teamsChannel struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// teamsClient talks to the Microsoft Graph API, authenticating through the
+// OAuth client credentials flow.
+type teamsClient struct {
+	httpClient   *http.Client
+	graphURL     string
+	loginURL     string
+	tenantID     string
+	clientID     string
+	clientSecret string
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	// usersByEmail caches users by email address.
+	usersByEmail map[string]*teamsUser
+}
+
+func newTeamsClient(tenantID, clientID, clientSecret, graphURL, loginURL string) *teamsClient {
+	if graphURL == "" {
+		graphURL = defaultTeamsGraphURL
+	}
+	if loginURL == "" {
+		loginURL = defaultTeamsLoginURL
+	}
+
+	return &teamsClient{
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		graphURL:     strings.TrimSuffix(graphURL, "/"),
+		loginURL:     strings.TrimSuffix(loginURL, "/"),
+		tenantID:     tenantID,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		usersByEmail: map[string]*teamsUser{},
+	}
+}
+
+func (cl *teamsClient) getUserByEmail(ctx context.Context, email string) (*teamsUser, error) {
+	cl.mu.Lock()
+	user, ok := cl.usersByEmail[email]
+	cl.mu.Unlock()
+	if ok {
+		return user, nil
+	}
+
+	filterEmail := strings.ReplaceAll(email, "'", "''")
+	query := url.Values{
+		"$filter": {fmt.Sprintf("mail eq '%s' or userPrincipalName eq '%s'", filterEmail, filterEmail)},
+		"$select": {"id,displayName"},
+	}
+	var resp struct {
+		Value []teamsUser `json:"value"`
+	}
+	if err := cl.do(ctx, http.MethodGet, "/users?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Value) == 0 {
+		return nil, nil
+	}
+
+	user = &resp.Value[0]
+	cl.mu.Lock()
+	cl.usersByEmail[email] = user
+	cl.mu.Unlock()
+
+	return user, nil
+}
+
+func (cl *teamsClient) getTags(ctx context.Context, teamID string) ([]teamsTag, error) {
+	var resp struct {
+		Value []teamsTag `json:"value"`
+	}
+	if err := cl.do(ctx, http.MethodGet, "/teams/"+url.PathEscape(teamID)+"/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Value, nil
+}
+
+func (cl *teamsClient) updateTagMembers(ctx context.Context, teamID string, tag runTeamsTag, userIDs []string, dryRun bool) error {
+	membersPath := "/teams/" + url.PathEscape(teamID) + "/tags/" + url.PathEscape(tag.id) + "/members"
+	var resp struct {
+		Value []teamsTagMember `json:"value"`
+	}
+	if err := cl.do(ctx, http.MethodGet, membersPath, nil, &resp); err != nil {
+		return fmt.Errorf("failed to get members of tag %q: %s", tag.name, err)
+	}
+
+	var toAdd []string
+	for _, userID := range userIDs {
+		found := false
+		for _, member := range resp.Value {
+			if member.UserID == userID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			toAdd = append(toAdd, userID)
+		}
+	}
+	var toRemove []teamsTagMember
+	for _, member := range resp.Value {
+		if !containsString(userIDs, member.UserID) {
+			toRemove = append(toRemove, member)
+		}
+	}
+
+	if len(toAdd) == 0 && len(toRemove) == 0 {
+		fmt.Printf("Tag %q already has the right members\n", tag.name)
+		return nil
+	}
+	if len(userIDs) == 0 {
+		// Tags cannot be emptied.
+		fmt.Printf("Not updating tag %q since there are no on-call users and tags need at least one member\n", tag.name)
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not updating tag %s with member(s): %s\n", tag.name, strings.Join(userIDs, ","))
+		return nil
+	}
+
+	// Add new members before removing old ones so that the tag never becomes
+	// empty.
+	for _, userID := range toAdd {
+		if err := cl.do(ctx, http.MethodPost, membersPath, map[string]string{"userId": userID}, nil); err != nil {
+			return fmt.Errorf("failed to add member %s to tag %q: %s", userID, tag.name, err)
+		}
+	}
+	for _, member := range toRemove {
+		if err := cl.do(ctx, http.MethodDelete, membersPath+"/"+url.PathEscape(member.ID), nil, nil); err != nil {
+			return fmt.Errorf("failed to remove member %s from tag %q: %s", member.UserID, tag.name, err)
+		}
+	}
+	fmt.Printf("Updated tag %s with member(s): %s\n", tag.name, strings.Join(userIDs, ","))
+
+	return nil
+}
+
+func (cl *teamsClient) updateChannelDescription(ctx context.Context, teamID, channelID, description string, dryRun bool) error {
+	channelPath := "/teams/" + url.PathEscape(teamID) + "/channels/" + url.PathEscape(channelID)
+	var channel teamsChannel
+	if err := cl.do(ctx, http.MethodGet, channelPath, nil, &channel); err != nil {
+		return err
+	}
+
+	if channel.Description == description {
+		fmt.Println("Microsoft Teams channel description already set correctly")
+		return nil
+	}
+
+	fmt.Printf("Updating Microsoft Teams channel description from\n[BEGIN-OF-OLD]\n%s\n[END-OF-OLD]\nto:\n[BEGIN-OF-NEW]\n%s\n[END-OF-NEW]\n", channel.Description, description)
+	if dryRun {
+		fmt.Println("[DRY RUN] Not updating Microsoft Teams channel description")
+		return nil
+	}
+	if err := cl.do(ctx, http.MethodPatch, channelPath, map[string]string{"description": description}, nil); err != nil {
+		return err
+	}
+	fmt.Println("Microsoft Teams channel description updated")
+
+	return nil
+}
+
+func (cl *teamsClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	token, err := cl.getToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %s", err)
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	return retryOnHTTPRateLimit("Microsoft Graph", func() error {
+		return doJSONRequest(ctx, cl.httpClient, method, cl.graphURL+path, header, in, out)
+	})
+}
+
+// getToken returns a cached access token, requesting a new one shortly before
+// the cached one expires.
+func (cl *teamsClient) getToken(ctx context.Context) (string, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.token != "" && time.Now().Before(cl.tokenExpiry) {
+		return cl.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cl.clientID},
+		"client_secret": {cl.clientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	}
+	tokenURL := cl.loginURL + "/" + url.PathEscape(cl.tenantID) + "/oauth2/v2.0/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := decodeJSONResponse(cl.httpClient, req, &tokenResp); err != nil {
+		return "", err
+	}
+
+	cl.token = tokenResp.AccessToken
+	cl.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - 1*time.Minute)
+
+	return cl.token, nil
+}
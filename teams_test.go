@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeGraph is a minimal stand-in for the Microsoft Graph API.
+type fakeGraph struct {
+	description string
+	tagMembers  map[string]string // member ID -> user ID
+	nextID      int
+}
+
+func (fg *fakeGraph) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "token", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/graph/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/graph/users":
+			if r.URL.Query().Get("$filter") == "mail eq 'jane@example.com' or userPrincipalName eq 'jane@example.com'" {
+				fmt.Fprint(w, `{"value": [{"id": "user-jane", "displayName": "Jane Doe"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"value": []}`)
+		case r.URL.Path == "/graph/teams/team/tags/tag-1/members" && r.Method == http.MethodGet:
+			var members []teamsTagMember
+			for id, userID := range fg.tagMembers {
+				members = append(members, teamsTagMember{ID: id, UserID: userID})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"value": members})
+		case r.URL.Path == "/graph/teams/team/tags/tag-1/members" && r.Method == http.MethodPost:
+			var req struct {
+				UserID string `json:"userId"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			fg.nextID++
+			fg.tagMembers[fmt.Sprintf("member-%d", fg.nextID)] = req.UserID
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{}`)
+		case r.URL.Path == "/graph/teams/team/tags/tag-1/members/member-0" && r.Method == http.MethodDelete:
+			delete(fg.tagMembers, "member-0")
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/graph/teams/team/channels/channel" && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(teamsChannel{ID: "channel", Description: fg.description})
+		case r.URL.Path == "/graph/teams/team/channels/channel" && r.Method == http.MethodPatch:
+			var req struct {
+				Description string `json:"description"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			fg.description = req.Description
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	return mux
+}
+
+func TestRunTeamsSync(t *testing.T) {
+	fg := &fakeGraph{
+		description: "old description",
+		tagMembers:  map[string]string{"member-0": "user-john"},
+	}
+	srv := httptest.NewServer(fg.handler(t))
+	defer srv.Close()
+
+	s := newSyncer(syncerParams{
+		teamsClient: newTeamsClient("tenant", "client", "secret", srv.URL+"/graph", srv.URL),
+	})
+	teamsSync := runTeamsSync{
+		teamID:    "team",
+		channelID: "channel",
+		tmpl:      template.Must(template.New("description").Funcs(teamsTemplateFuncs).Parse("on-call: {{mentions .Primary}}")),
+		tags: []runTeamsTag{
+			{id: "tag-1", name: "oncall"},
+		},
+	}
+	onCalls := []scheduleOnCall{
+		{
+			schedule: oncallSchedule{id: "sched-1", name: "Primary"},
+			users:    []oncallUser{{id: "PD1", name: "Jane Doe", email: "jane@example.com"}},
+		},
+	}
+
+	if err := s.runTeamsSync(context.Background(), teamsSync, onCalls, true); err != nil {
+		t.Fatalf("failed to run dry-run Microsoft Teams sync: %s", err)
+	}
+	if fg.description != "old description" {
+		t.Errorf("got description %q in dry-run mode, want it unchanged", fg.description)
+	}
+
+	if err := s.runTeamsSync(context.Background(), teamsSync, onCalls, false); err != nil {
+		t.Fatalf("failed to run Microsoft Teams sync: %s", err)
+	}
+	if want := "on-call: Jane Doe"; fg.description != want {
+		t.Errorf("got description %q, want %q", fg.description, want)
+	}
+	wantMembers := map[string]string{"member-1": "user-jane"}
+	if diff := cmp.Diff(wantMembers, fg.tagMembers); diff != "" {
+		t.Errorf("tag members mismatch (-want +got):\n%s", diff)
+	}
+}
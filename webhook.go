@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/matryer/try"
+)
+
+const (
+	// webhookSignatureHeader carries the hex-encoded HMAC-SHA256 of the
+	// request body, prefixed by "sha256=".
+	webhookSignatureHeader = "X-Pdsync-Signature"
+	webhookMaxAttempts     = 5
+)
+
+type runWebhookSync struct {
+	url    string
+	secret string
+}
+
+// webhookPayload is the JSON document sent to webhooks.
+type webhookPayload struct {
+	SyncName  string            `json:"syncName"`
+	Schedules []webhookSchedule `json:"schedules"`
+}
+
+type webhookSchedule struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name"`
+	Changed bool          `json:"changed"`
+	OnCall  []webhookUser `json:"onCall"`
+	// Previous holds the users on call during the last run, or nil if unknown.
+	Previous []webhookUser `json:"previous"`
+}
+
+type webhookUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	SlackID string `json:"slackID,omitempty"`
+}
+
+func (sp syncerParams) createWebhookSync(cfgWebhook ConfigWebhook) (*runWebhookSync, error) {
+	if sp.webhookClient == nil {
+		return nil, errors.New("webhook client is not configured")
+	}
+
+	return &runWebhookSync{
+		url:    cfgWebhook.URL,
+		secret: cfgWebhook.Secret,
+	}, nil
+}
+
+// runWebhookSync notifies the webhook if the on-call users of any schedule
+// differ from the previous ones. Unknown previous on-calls (i.e., on the first
+// run) always count as a change.
+func (s *syncer) runWebhookSync(ctx context.Context, whSync runWebhookSync, slSyncName string, slUsers slackUsers, prevOnCalls, onCalls []scheduleOnCall, dryRun bool) error {
+	if prevOnCalls != nil && !onCallsChanged(prevOnCalls, onCalls) {
+		fmt.Println("On-call users unchanged, not sending webhook")
+		return nil
+	}
+
+	payload := webhookPayload{
+		SyncName:  slSyncName,
+		Schedules: make([]webhookSchedule, 0, len(onCalls)),
+	}
+	for _, onCall := range onCalls {
+		whSchedule := webhookSchedule{
+			ID:     onCall.schedule.id,
+			Name:   onCall.schedule.name,
+			OnCall: webhookUsers(onCall.users, slUsers),
+		}
+		prevUsers, known := findOnCallUsers(prevOnCalls, onCall.schedule.id)
+		if known {
+			whSchedule.Previous = webhookUsers(prevUsers, slUsers)
+		}
+		whSchedule.Changed = !known || !sameOnCallUsers(prevUsers, onCall.users)
+		payload.Schedules = append(payload.Schedules, whSchedule)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Not sending webhook to %s\n", whSync.url)
+		return nil
+	}
+
+	if err := s.webhookClient.send(ctx, whSync.url, whSync.secret, payload); err != nil {
+		return fmt.Errorf("failed to send webhook to %s: %s", whSync.url, err)
+	}
+	fmt.Printf("Sent webhook to %s\n", whSync.url)
+
+	return nil
+}
+
+func webhookUsers(users []oncallUser, slUsers slackUsers) []webhookUser {
+	whUsers := make([]webhookUser, 0, len(users))
+	for _, user := range users {
+		whUser := webhookUser{
+			ID:    user.id,
+			Name:  user.name,
+			Email: user.email,
+		}
+		if slUser := slUsers.findByOncallUser(user); slUser != nil {
+			whUser.SlackID = slUser.id
+		}
+		whUsers = append(whUsers, whUser)
+	}
+	return whUsers
+}
+
+type webhookClient struct {
+	httpClient *http.Client
+	// retryDelay is the base delay between delivery attempts. It is
+	// multiplied by the attempt number.
+	retryDelay time.Duration
+}
+
+func newWebhookClient() *webhookClient {
+	return &webhookClient{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retryDelay: 5 * time.Second,
+	}
+}
+
+// send POSTs the payload to the given URL, signing it with the secret if
+// non-empty. Server errors, rate limits, and connection errors are retried.
+func (cl *webhookClient) send(ctx context.Context, url, secret string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %s", err)
+	}
+
+	return try.Do(func(attempt int) (retry bool, retryErr error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return false, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookBody(secret, body))
+		}
+
+		err = decodeJSONResponse(cl.httpClient, req, nil)
+		if err == nil || attempt >= webhookMaxAttempts || ctx.Err() != nil {
+			return false, err
+		}
+		var se *httpStatusError
+		if errors.As(err, &se) && se.statusCode < http.StatusInternalServerError && se.statusCode != http.StatusTooManyRequests {
+			return false, err
+		}
+
+		sleep := cl.retryDelay * time.Duration(attempt)
+		if se != nil && se.retryAfter > 0 {
+			sleep = se.retryAfter
+		}
+		fmt.Printf("Webhook delivery attempt %d failed (%s) -- retrying in %s\n", attempt, err, sleep)
+		time.Sleep(sleep)
+		return true, err
+	})
+}
+
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRunWebhookSync(t *testing.T) {
+	var (
+		requests int
+		payloads []webhookPayload
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Fail the first delivery attempt to exercise retries.
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %s", err)
+			return
+		}
+		if got, want := r.Header.Get(webhookSignatureHeader), "sha256="+signWebhookBody("secret", body); got != want {
+			t.Errorf("got signature %q, want %q", got, want)
+		}
+		var payload webhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("failed to decode payload: %s", err)
+			return
+		}
+		payloads = append(payloads, payload)
+	}))
+	defer srv.Close()
+
+	whClient := newWebhookClient()
+	whClient.retryDelay = 0
+	s := newSyncer(syncerParams{webhookClient: whClient})
+	slUsers := slackUsers{
+		{id: "U1", email: "jane@example.com"},
+	}
+	whSync := runWebhookSync{url: srv.URL, secret: "secret"}
+
+	jane := oncallUser{id: "PD1", name: "Jane Doe", email: "jane@example.com"}
+	john := oncallUser{id: "PD2", name: "John Doe", email: "john@example.com"}
+	onCallsJane := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{jane}}}
+	onCallsJohn := []scheduleOnCall{{schedule: oncallSchedule{id: "S1", name: "Primary"}, users: []oncallUser{john}}}
+
+	if err := s.runWebhookSync(context.Background(), whSync, "team", slUsers, nil, onCallsJane, true); err != nil {
+		t.Fatalf("failed to run dry-run webhook sync: %s", err)
+	}
+	if requests != 0 {
+		t.Fatalf("got %d request(s) in dry-run mode, want none", requests)
+	}
+
+	for _, run := range []struct {
+		prev []scheduleOnCall
+		cur  []scheduleOnCall
+	}{
+		{prev: nil, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJane},
+		{prev: onCallsJane, cur: onCallsJohn},
+	} {
+		if err := s.runWebhookSync(context.Background(), whSync, "team", slUsers, run.prev, run.cur, false); err != nil {
+			t.Fatalf("failed to run webhook sync: %s", err)
+		}
+	}
+
+	janeUser := webhookUser{ID: "PD1", Name: "Jane Doe", Email: "jane@example.com", SlackID: "U1"}
+	johnUser := webhookUser{ID: "PD2", Name: "John Doe", Email: "john@example.com"}
+	wantPayloads := []webhookPayload{
+		{
+			SyncName: "team",
+			Schedules: []webhookSchedule{
+				{ID: "S1", Name: "Primary", Changed: true, OnCall: []webhookUser{janeUser}},
+			},
+		},
+		{
+			SyncName: "team",
+			Schedules: []webhookSchedule{
+				{ID: "S1", Name: "Primary", Changed: true, OnCall: []webhookUser{johnUser}, Previous: []webhookUser{janeUser}},
+			},
+		},
+	}
+	if diff := cmp.Diff(wantPayloads, payloads); diff != "" {
+		t.Errorf("payloads mismatch (-want +got):\n%s", diff)
+	}
+}